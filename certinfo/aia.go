@@ -0,0 +1,238 @@
+package certinfo
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//FindingIncompleteChain - server does not send intermediate certificates, but chain can be built with AIA fetching
+const FindingIncompleteChain FindingCode = "incomplete_chain"
+
+//maxAIACertSize - max size of certificate downloaded from AIA caIssuers URL
+const maxAIACertSize = 1 << 20
+
+//maxAIAFetches - max count of certificates fetched to complete served chain
+const maxAIAFetches = 4
+
+//oidSignedData - PKCS#7 signed data content type, used by .p7c files with certificates
+var oidSignedData = []byte{0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x07, 0x02}
+
+//CompleteChain fetches missing intermediate certificates from AIA caIssuers URLs until served chain can be verified
+//roots - trusted roots. If roots is nil - system roots are used
+//Issuer is fetched for the first certificate, which is not signed by next certificate of chain
+//Returns fetched certificates in chain order, empty if served chain is already complete
+func (c *Checker) CompleteChain(ctx context.Context, certs []*x509.Certificate, roots *x509.CertPool, now time.Time) ([]*x509.Certificate, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("aia error - server did not send any certificate")
+	}
+	chain := append([]*x509.Certificate{}, certs...)
+	var fetched []*x509.Certificate
+	for {
+		if chainVerifies(chain, roots, now) {
+			return fetched, nil
+		}
+		if len(fetched) == maxAIAFetches {
+			return nil, fmt.Errorf("aia error - chain is not complete after %d fetched certificates", maxAIAFetches)
+		}
+		index := chainBreak(chain)
+		broken := chain[index]
+		if isSelfSigned(broken) {
+			return nil, fmt.Errorf("aia error - certificate %s is self-signed", broken.Subject.CommonName)
+		}
+		if len(broken.IssuingCertificateURL) == 0 {
+			return nil, fmt.Errorf("aia error - certificate %s has no caIssuers URL", broken.Subject.CommonName)
+		}
+		issuer, err := c.fetchIssuer(ctx, broken)
+		if err != nil {
+			return nil, err
+		}
+		fetched = append(fetched, issuer)
+		chain = append(chain[:index+1], append([]*x509.Certificate{issuer}, chain[index+1:]...)...)
+	}
+}
+
+//chainVerifies checks that leaf certificate can be verified with chain certificates as intermediates
+//Validity period is not checked, it is reported separately by VerifyChain
+func chainVerifies(chain []*x509.Certificate, roots *x509.CertPool, now time.Time) bool {
+	return len(verifiedChains(chain, roots, now)) > 0
+}
+
+//verifiedChains returns chains from leaf certificate to trusted root, chain certificates are used as intermediates
+//Returned chains contain root from roots, even if it is not served. Validity period is not checked
+func verifiedChains(chain []*x509.Certificate, roots *x509.CertPool, now time.Time) [][]*x509.Certificate {
+	leaf := chain[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	opts := x509.VerifyOptions{Roots: roots, Intermediates: intermediates, CurrentTime: now}
+	chains, err := leaf.Verify(opts)
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired {
+		opts.CurrentTime = leaf.NotBefore.Add(leaf.NotAfter.Sub(leaf.NotBefore) / 2)
+		chains, err = leaf.Verify(opts)
+	}
+	if err != nil {
+		return nil
+	}
+	return chains
+}
+
+//fetchIssuer downloads certificates from caIssuers URLs of cert and returns the one, which signs cert
+func (c *Checker) fetchIssuer(ctx context.Context, cert *x509.Certificate) (*x509.Certificate, error) {
+	var errs []string
+	for _, URL := range cert.IssuingCertificateURL {
+		candidates, err := c.downloadAIACertificates(ctx, URL)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", URL, err))
+			continue
+		}
+		for _, candidate := range candidates {
+			if cert.CheckSignatureFrom(candidate) == nil {
+				return candidate, nil
+			}
+		}
+		errs = append(errs, fmt.Sprintf("%s: certificate is not issuer of %s", URL, cert.Subject.CommonName))
+	}
+	return nil, fmt.Errorf("aia error - cannot fetch issuer of %s (%s)", cert.Subject.CommonName, strings.Join(errs, "; "))
+}
+
+//downloadAIACertificates downloads DER, PEM or PKCS#7 encoded certificates from caIssuers URL
+func (c *Checker) downloadAIACertificates(ctx context.Context, URL string) ([]*x509.Certificate, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.httpClient().Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returns %s", response.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(response.Body, maxAIACertSize))
+	if err != nil {
+		return nil, err
+	}
+	return parseAIACertificates(raw)
+}
+
+//parseAIACertificates parses certificates in DER, PEM or PKCS#7 certs-only format (RFC 5280, 4.2.2.1)
+func parseAIACertificates(raw []byte) ([]*x509.Certificate, error) {
+	if block, _ := pem.Decode(raw); block != nil {
+		var certs []*x509.Certificate
+		for ; block != nil; block, raw = pem.Decode(raw) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse certificate (%v)", err)
+			}
+			certs = append(certs, cert)
+		}
+		if len(certs) == 0 {
+			return nil, errors.New("no certificates in PEM data")
+		}
+		return certs, nil
+	}
+	if cert, err := x509.ParseCertificate(raw); err == nil {
+		return []*x509.Certificate{cert}, nil
+	}
+	certs, err := parsePKCS7Certificates(raw)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse certificate (%v)", err)
+	}
+	return certs, nil
+}
+
+//parsePKCS7Certificates parses certificates of PKCS#7 signed data (RFC 2315, 9.1)
+func parsePKCS7Certificates(raw []byte) ([]*x509.Certificate, error) {
+	input := cryptobyte.String(raw)
+	var contentInfo, contentType, content, signedData, certificates cryptobyte.String
+	var version int64
+	if !input.ReadASN1(&contentInfo, cryptobyte_asn1.SEQUENCE) ||
+		!contentInfo.ReadASN1(&contentType, cryptobyte_asn1.OBJECT_IDENTIFIER) ||
+		!bytes.Equal(contentType, oidSignedData) ||
+		!contentInfo.ReadASN1(&content, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) ||
+		!content.ReadASN1(&signedData, cryptobyte_asn1.SEQUENCE) ||
+		!signedData.ReadASN1Integer(&version) ||
+		!signedData.SkipASN1(cryptobyte_asn1.SET) ||
+		!signedData.SkipASN1(cryptobyte_asn1.SEQUENCE) ||
+		!signedData.ReadASN1(&certificates, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		return nil, errors.New("malformed PKCS#7 data")
+	}
+	var certs []*x509.Certificate
+	for !certificates.Empty() {
+		var element cryptobyte.String
+		if !certificates.ReadASN1Element(&element, cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("malformed PKCS#7 certificates")
+		}
+		cert, err := x509.ParseCertificate(element)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates in PKCS#7 data")
+	}
+	return certs, nil
+}
+
+//checkChainCompleteness fetches missing intermediate certificates if served chain has missing intermediate finding
+//missing intermediate finding is replaced with incomplete chain finding, if chain is completed with fetched certificates
+func (c *Checker) checkChainCompleteness(ctx context.Context, certs []*x509.Certificate, findings []Finding, now time.Time) ([]*x509.Certificate, []Finding) {
+	index := -1
+	for i, finding := range findings {
+		if finding.Code == FindingMissingIntermediate {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return nil, findings
+	}
+	fetched, err := c.CompleteChain(ctx, certs, c.Roots, now)
+	if err != nil || len(fetched) == 0 {
+		return nil, findings
+	}
+
+	var missing []string
+	for _, cert := range fetched {
+		missing = append(missing, cert.Subject.CommonName)
+	}
+	result := append([]Finding{}, findings[:index]...)
+	result = append(result, Finding{
+		Code:     FindingIncompleteChain,
+		Severity: SeverityWarning,
+		Message: fmt.Sprintf("incomplete chain, missing %s - server does not send intermediate certificates, clients without AIA fetching cannot verify chain",
+			strings.Join(missing, ", ")),
+	})
+	return fetched, append(result, findings[index+1:]...)
+}
+
+//FullChainPEM returns PEM encoded served chain with missing intermediate certificates fetched via AIA
+func (r *CertReport) FullChainPEM() []byte {
+	var buffer bytes.Buffer
+	for _, chain := range [][]CertDetails{r.Chain, r.FetchedChain} {
+		for _, cert := range chain {
+			if cert.Certificate == nil {
+				continue
+			}
+			_ = pem.Encode(&buffer, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate.Raw})
+		}
+	}
+	return buffer.Bytes()
+}
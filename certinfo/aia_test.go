@@ -0,0 +1,189 @@
+package certinfo
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//newTestPKCS7 creates PKCS#7 signed data with certificates only, like .p7c files
+func newTestPKCS7(t *testing.T, certs ...*x509.Certificate) []byte {
+	builder := cryptobyte.NewBuilder(nil)
+	builder.AddASN1(cryptobyte_asn1.SEQUENCE, func(contentInfo *cryptobyte.Builder) {
+		contentInfo.AddASN1(cryptobyte_asn1.OBJECT_IDENTIFIER, func(oid *cryptobyte.Builder) {
+			oid.AddBytes(oidSignedData)
+		})
+		contentInfo.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(content *cryptobyte.Builder) {
+			content.AddASN1(cryptobyte_asn1.SEQUENCE, func(signedData *cryptobyte.Builder) {
+				signedData.AddASN1Int64(1)
+				signedData.AddASN1(cryptobyte_asn1.SET, func(*cryptobyte.Builder) {})
+				signedData.AddASN1(cryptobyte_asn1.SEQUENCE, func(data *cryptobyte.Builder) {
+					data.AddASN1ObjectIdentifier([]int{1, 2, 840, 113549, 1, 7, 1})
+				})
+				signedData.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(certificates *cryptobyte.Builder) {
+					for _, cert := range certs {
+						certificates.AddBytes(cert.Raw)
+					}
+				})
+				signedData.AddASN1(cryptobyte_asn1.SET, func(*cryptobyte.Builder) {})
+			})
+		})
+	})
+	raw, err := builder.Bytes()
+	if err != nil {
+		t.Fatalf("cannot create PKCS#7 data: %v", err)
+	}
+	return raw
+}
+
+//startTestAIAServer starts HTTP server, which serves files by path
+func startTestAIAServer(t *testing.T, files map[string][]byte) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestChecker_CompleteChain(t *testing.T) {
+	files := map[string][]byte{}
+	serverURL := startTestAIAServer(t, files).URL
+
+	caNotAfter := time.Now().Add(24 * time.Hour * 365)
+	root := newTestCert(t, testCertOptions{commonName: "Test Root CA", isCA: true, notAfter: caNotAfter}, nil)
+	intermediate := newTestCert(t, testCertOptions{commonName: "Test Intermediate CA", isCA: true, notAfter: caNotAfter,
+		caIssuers: []string{serverURL + "/root.crt"}}, root)
+	subIntermediate := newTestCert(t, testCertOptions{commonName: "Test Sub Intermediate CA", isCA: true, notAfter: caNotAfter,
+		caIssuers: []string{serverURL + "/intermediate.p7c"}}, intermediate)
+	otherCA := newTestCert(t, testCertOptions{commonName: "Other CA", isCA: true, notAfter: caNotAfter}, root)
+	files["/intermediate.crt"] = intermediate.cert.Raw
+	files["/intermediate.pem"] = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: intermediate.cert.Raw})
+	files["/intermediate.p7c"] = newTestPKCS7(t, otherCA.cert, intermediate.cert)
+	files["/sub.crt"] = subIntermediate.cert.Raw
+	files["/other.crt"] = otherCA.cert.Raw
+	files["/broken.crt"] = []byte("not a certificate")
+
+	newLeaf := func(parent *testCert, caIssuers ...string) *x509.Certificate {
+		return newTestCert(t, testCertOptions{commonName: "example.com", dnsNames: []string{"example.com"}, caIssuers: caIssuers}, parent).cert
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+
+	tests := []struct {
+		name    string
+		certs   []*x509.Certificate
+		want    []*x509.Certificate
+		wantErr bool
+	}{
+		{
+			name:  "test complete chain",
+			certs: []*x509.Certificate{newLeaf(intermediate, serverURL+"/intermediate.crt"), intermediate.cert},
+			want:  nil,
+		},
+		{
+			name:  "test DER certificate",
+			certs: []*x509.Certificate{newLeaf(intermediate, serverURL+"/intermediate.crt")},
+			want:  []*x509.Certificate{intermediate.cert},
+		},
+		{
+			name:  "test PEM certificate",
+			certs: []*x509.Certificate{newLeaf(intermediate, serverURL+"/intermediate.pem")},
+			want:  []*x509.Certificate{intermediate.cert},
+		},
+		{
+			name:  "test PKCS#7 certificates",
+			certs: []*x509.Certificate{newLeaf(intermediate, serverURL+"/intermediate.p7c")},
+			want:  []*x509.Certificate{intermediate.cert},
+		},
+		{
+			name:  "test second URL",
+			certs: []*x509.Certificate{newLeaf(intermediate, serverURL+"/missing.crt", serverURL+"/intermediate.crt")},
+			want:  []*x509.Certificate{intermediate.cert},
+		},
+		{
+			name:  "test few missing certificates",
+			certs: []*x509.Certificate{newLeaf(subIntermediate, serverURL+"/sub.crt")},
+			want:  []*x509.Certificate{subIntermediate.cert, intermediate.cert},
+		},
+		{
+			name:  "test missing intermediate with served root",
+			certs: []*x509.Certificate{newLeaf(intermediate, serverURL+"/intermediate.crt"), root.cert},
+			want:  []*x509.Certificate{intermediate.cert},
+		},
+		{
+			name:    "test self-signed certificate",
+			certs:   []*x509.Certificate{newTestCert(t, testCertOptions{commonName: "example.com", dnsNames: []string{"example.com"}}, nil).cert},
+			wantErr: true,
+		},
+		{
+			name:    "test no caIssuers URL",
+			certs:   []*x509.Certificate{newLeaf(intermediate)},
+			wantErr: true,
+		},
+		{
+			name:    "test not issuer certificate",
+			certs:   []*x509.Certificate{newLeaf(intermediate, serverURL+"/other.crt")},
+			wantErr: true,
+		},
+		{
+			name:    "test malformed certificate",
+			certs:   []*x509.Certificate{newLeaf(intermediate, serverURL+"/broken.crt")},
+			wantErr: true,
+		},
+		{
+			name:    "test empty chain",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := &Checker{}
+			got, err := checker.CompleteChain(context.Background(), tt.certs, roots, time.Now())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompleteChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CompleteChain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCertReport_FullChainPEM(t *testing.T) {
+	leaf, intermediate, _ := newTestChain(t, "example.com")
+	report := &CertReport{
+		Chain:        []CertDetails{newCertDetails(0, leaf.cert)},
+		FetchedChain: []CertDetails{newCertDetails(1, intermediate.cert)},
+	}
+
+	rest := report.FullChainPEM()
+	var got [][]byte
+	for block, next := pem.Decode(rest); block != nil; block, next = pem.Decode(next) {
+		if block.Type != "CERTIFICATE" {
+			t.Errorf("FullChainPEM() block type = %s, want CERTIFICATE", block.Type)
+		}
+		got = append(got, block.Bytes)
+	}
+	want := [][]byte{leaf.cert.Raw, intermediate.cert.Raw}
+	if len(got) != len(want) {
+		t.Fatalf("FullChainPEM() certificates count = %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("FullChainPEM() certificate %d does not match", i)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package certinfo
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+//Audit findings
+const (
+	FindingWeakProtocol     FindingCode = "weak_protocol"
+	FindingWeakCipherSuite  FindingCode = "weak_cipher_suite"
+	FindingNoModernProtocol FindingCode = "no_modern_protocol"
+)
+
+//auditVersions TLS versions checked by audit, from oldest to newest
+var auditVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+//auditNotTested protocols and cipher suites, which cannot be offered by Go TLS client, so they are never probed by audit
+var auditNotTested = []string{"SSL 3.0", "DHE cipher suites", "export cipher suites", "NULL cipher suites", "anonymous cipher suites"}
+
+//VersionAudit result of audit of single TLS version
+type VersionAudit struct {
+	Version   string `json:"version"`
+	Supported bool   `json:"supported"`
+	//CipherSuites - accepted cipher suites in server preference order
+	//For TLS 1.3 contains only negotiated cipher suite, because TLS 1.3 cipher suites cannot be restricted
+	CipherSuites []string `json:"cipher_suites,omitempty"`
+}
+
+//AuditReport result of TLS protocol and cipher suites audit
+type AuditReport struct {
+	Target   string         `json:"target"`
+	Endpoint string         `json:"endpoint,omitempty"`
+	IP       string         `json:"ip,omitempty"`
+	Versions []VersionAudit `json:"versions,omitempty"`
+	//NotTested - protocols and cipher suites, which are not probed, their support is unknown
+	NotTested []string `json:"not_tested,omitempty"`
+	//SCT - Certificate Transparency check of leaf certificate, nil if log list is not configured
+	SCT      *SCTReport `json:"sct,omitempty"`
+	Findings []Finding  `json:"findings,omitempty"`
+	//Handshakes - count of made handshakes
+	Handshakes int           `json:"handshakes"`
+	StartedAt  time.Time     `json:"started_at"`
+	Duration   time.Duration `json:"duration"`
+
+	ErrorCategory ErrorCategory `json:"error_category,omitempty"`
+	ErrorMessage  string        `json:"error,omitempty"`
+	Err           error         `json:"-"`
+}
+
+//setError sets audit error with category
+func (r *AuditReport) setError(category ErrorCategory, err error) {
+	r.ErrorCategory = category
+	r.ErrorMessage = err.Error()
+	r.Err = err
+}
+
+//AuditSummary short result of audit, which is stored between scheduled audits
+type AuditSummary struct {
+	//Versions - supported TLS versions
+	Versions []string `json:"versions"`
+	//WeakCipherSuites - accepted weak cipher suites
+	WeakCipherSuites []string `json:"weak_cipher_suites"`
+}
+
+//Summary returns short result of audit
+func (r *AuditReport) Summary() AuditSummary {
+	summary := AuditSummary{Versions: []string{}, WeakCipherSuites: []string{}}
+	for _, version := range r.Versions {
+		if !version.Supported {
+			continue
+		}
+		summary.Versions = append(summary.Versions, version.Version)
+		for _, suite := range version.CipherSuites {
+			if cipherSuiteWeakness(suite) != "" && !stringInSlice(suite, summary.WeakCipherSuites) {
+				summary.WeakCipherSuites = append(summary.WeakCipherSuites, suite)
+			}
+		}
+	}
+	return summary
+}
+
+//AuditRegressions returns descriptions of changes, which make current audit result worse than previous
+func AuditRegressions(previous, current AuditSummary) []string {
+	var regressions []string
+	for _, version := range current.Versions {
+		if isWeakVersionName(version) && !stringInSlice(version, previous.Versions) {
+			regressions = append(regressions, fmt.Sprintf("weak protocol %s is accepted now", version))
+		}
+	}
+	for _, version := range previous.Versions {
+		if !isWeakVersionName(version) && !stringInSlice(version, current.Versions) {
+			regressions = append(regressions, fmt.Sprintf("protocol %s is not supported anymore", version))
+		}
+	}
+	for _, suite := range current.WeakCipherSuites {
+		if !stringInSlice(suite, previous.WeakCipherSuites) {
+			regressions = append(regressions, fmt.Sprintf("weak cipher suite %s is accepted now", suite))
+		}
+	}
+	return regressions
+}
+
+//Audit checks TLS versions and cipher suites, which are accepted by target
+//URL - target in the same formats as in Check
+//Errors are returned in report
+func (c *Checker) Audit(ctx context.Context, URL string) *AuditReport {
+	target, err := ParseTarget(URL)
+	if err != nil {
+		report := &AuditReport{Target: URL, StartedAt: time.Now()}
+		report.setError(ErrorCategoryTarget, err)
+		report.Duration = time.Since(report.StartedAt)
+		return report
+	}
+	return c.AuditTarget(ctx, target)
+}
+
+//AuditTarget checks TLS versions and cipher suites, which are accepted by target
+//Only first resolved IP address (or target IP if specified) is audited, every check is separate handshake
+//SSL 3.0, DHE, export, NULL and anonymous cipher suites are not supported by Go TLS client and are not tested
+//Errors are returned in report
+func (c *Checker) AuditTarget(ctx context.Context, target *Target) *AuditReport {
+	auditedTarget := *target
+	if auditedTarget.Port == "" {
+		auditedTarget.Port = defaultTargetPort(auditedTarget.Protocol)
+	}
+	report := &AuditReport{
+		Target:    auditedTarget.String(),
+		Endpoint:  auditedTarget.Address(),
+		NotTested: auditNotTested,
+		StartedAt: time.Now(),
+	}
+	defer func() {
+		report.Duration = time.Since(report.StartedAt)
+	}()
+
+	ips, err := c.resolve(ctx, &auditedTarget)
+	if err != nil {
+		log.Println("Error in audit resolve", err)
+		report.setError(checkErrorCategory(err))
+		return report
+	}
+	report.IP = ips[0]
+	address := net.JoinHostPort(ips[0], auditedTarget.Port)
+
+	for _, version := range auditVersions {
+		versionAudit, err := c.auditVersion(ctx, &auditedTarget, address, version, &report.Handshakes)
+		if err != nil {
+			log.Println("Error in audit of", tlsVersionName(version), err)
+			report.setError(checkErrorCategory(err))
+			return report
+		}
+		report.Versions = append(report.Versions, versionAudit)
+	}
+	report.Findings = auditFindings(report.Versions)
+
+	if c.CTLogs != nil {
+		report.Handshakes++
+		sct, findings, err := c.auditSCTs(ctx, &auditedTarget, address)
+		if err != nil {
+			log.Println("Error in audit of SCTs", err)
+			report.setError(checkErrorCategory(err))
+			return report
+		}
+		report.SCT = sct
+		report.Findings = append(report.Findings, findings...)
+	}
+	return report
+}
+
+//auditSCTs makes handshake with default settings and checks SCTs of served leaf certificate
+func (c *Checker) auditSCTs(ctx context.Context, target *Target, address string) (*SCTReport, []Finding, error) {
+	conn, err := c.dialTarget(ctx, target, address, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	state := conn.ConnectionState()
+	_ = conn.Close()
+
+	certs := state.PeerCertificates
+	if len(certs) == 0 {
+		return nil, nil, nil
+	}
+	now := time.Now()
+	report, findings := c.CheckSCTs(certs[0], chainIssuer(certs), state.SignedCertificateTimestamps, state.OCSPResponse,
+		publiclyTrusted(certs, now), now)
+	return report, findings, nil
+}
+
+//auditVersion checks cipher suites of TLS version, which are accepted by server
+//Returns error only if server cannot be reached, rejected handshakes are not errors
+//handshakes - counter of made handshakes
+func (c *Checker) auditVersion(ctx context.Context, target *Target, address string, version uint16, handshakes *int) (VersionAudit, error) {
+	result := VersionAudit{Version: tlsVersionName(version)}
+
+	//TLS 1.3 cipher suites cannot be restricted, so only negotiated suite is checked
+	offered := []uint16{0}
+	if version != tls.VersionTLS13 {
+		offered = versionCipherSuites(version)
+	}
+	for len(offered) > 0 {
+		conf := &tls.Config{MinVersion: version, MaxVersion: version}
+		if version != tls.VersionTLS13 {
+			conf.CipherSuites = offered
+		}
+		*handshakes++
+		conn, err := c.dialTarget(ctx, target, address, conf)
+		if err != nil {
+			if category, _ := checkErrorCategory(err); category == ErrorCategoryHandshake {
+				break
+			}
+			return result, err
+		}
+		suite := conn.ConnectionState().CipherSuite
+		_ = conn.Close()
+
+		result.Supported = true
+		result.CipherSuites = append(result.CipherSuites, tls.CipherSuiteName(suite))
+		if version == tls.VersionTLS13 {
+			break
+		}
+		offered = removeCipherSuite(offered, suite)
+	}
+	return result, nil
+}
+
+//versionCipherSuites returns all cipher suites, which can be used with TLS version
+func versionCipherSuites(version uint16) []uint16 {
+	var suites []uint16
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		for _, supportedVersion := range suite.SupportedVersions {
+			if supportedVersion == version {
+				suites = append(suites, suite.ID)
+				break
+			}
+		}
+	}
+	return suites
+}
+
+func removeCipherSuite(suites []uint16, suite uint16) []uint16 {
+	var result []uint16
+	for _, s := range suites {
+		if s != suite {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+//auditFindings returns findings about weak TLS versions and cipher suites
+func auditFindings(versions []VersionAudit) []Finding {
+	var findings []Finding
+	modern := false
+	weakSuites := map[string]bool{}
+	for _, version := range versions {
+		if !version.Supported {
+			continue
+		}
+		if isWeakVersionName(version.Version) {
+			findings = append(findings, Finding{
+				Code:     FindingWeakProtocol,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("weak protocol %s is accepted", version.Version),
+			})
+		} else {
+			modern = true
+		}
+		for _, suite := range version.CipherSuites {
+			reason := cipherSuiteWeakness(suite)
+			if reason == "" || weakSuites[suite] {
+				continue
+			}
+			weakSuites[suite] = true
+			findings = append(findings, Finding{
+				Code:     FindingWeakCipherSuite,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("weak cipher suite %s is accepted (%s)", suite, reason),
+			})
+		}
+	}
+	if !modern {
+		findings = append(findings, Finding{
+			Code:     FindingNoModernProtocol,
+			Severity: SeverityCritical,
+			Message:  "neither TLS 1.2 nor TLS 1.3 is supported",
+		})
+	}
+	return findings
+}
+
+//isWeakVersionName returns true for deprecated TLS versions
+func isWeakVersionName(version string) bool {
+	return version == tlsVersionName(tls.VersionTLS10) || version == tlsVersionName(tls.VersionTLS11)
+}
+
+//cipherSuiteWeakness returns reason, why cipher suite is weak, empty if cipher suite is not weak
+func cipherSuiteWeakness(name string) string {
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.Name == name {
+			return "insecure cipher suite"
+		}
+	}
+	//TLS 1.3 cipher suites always use ephemeral key exchange
+	if strings.HasPrefix(name, "TLS_") && !strings.HasPrefix(name, "TLS_ECDHE_") && !strings.HasPrefix(name, "TLS_AES_") &&
+		!strings.HasPrefix(name, "TLS_CHACHA20_") {
+		return "no forward secrecy"
+	}
+	return ""
+}
+
+func stringInSlice(a string, list []string) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package certinfo
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestChecker_Audit(t *testing.T) {
+	leaf, intermediate, _ := newTestChain(t, "audit.example.com")
+
+	legacyConfig := newTestTLSConfig(leaf, intermediate)
+	legacyConfig.MinVersion = tls.VersionTLS10
+	legacyConfig.MaxVersion = tls.VersionTLS12
+	legacyConfig.CipherSuites = []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}
+	legacyAddress := startTestTLSServer(t, legacyConfig)
+
+	modernConfig := newTestTLSConfig(leaf, intermediate)
+	modernConfig.MinVersion = tls.VersionTLS13
+	modernAddress := startTestTLSServer(t, modernConfig)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot reserve port: %v", err)
+	}
+	closedAddress := listener.Addr().String()
+	_ = listener.Close()
+
+	tests := []struct {
+		name              string
+		URL               string
+		wantErrorCategory ErrorCategory
+		wantVersions      []VersionAudit
+		wantFindings      []FindingCode
+		wantHandshakes    int
+	}{
+		{
+			name: "test legacy server",
+			URL:  legacyAddress,
+			wantVersions: []VersionAudit{
+				{Version: "TLS 1.0", Supported: true, CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA"}},
+				{Version: "TLS 1.1", Supported: true, CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA"}},
+				{Version: "TLS 1.2", Supported: true, CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA"}},
+				{Version: "TLS 1.3"},
+			},
+			wantFindings:   []FindingCode{FindingWeakProtocol, FindingWeakProtocol},
+			wantHandshakes: 8,
+		},
+		{
+			name: "test modern server",
+			URL:  modernAddress,
+			wantVersions: []VersionAudit{
+				{Version: "TLS 1.0"},
+				{Version: "TLS 1.1"},
+				{Version: "TLS 1.2"},
+				{Version: "TLS 1.3", Supported: true, CipherSuites: []string{"TLS_AES_128_GCM_SHA256"}},
+			},
+			wantHandshakes: 4,
+		},
+		{
+			name:              "test closed port",
+			URL:               closedAddress,
+			wantErrorCategory: ErrorCategoryConnect,
+			wantHandshakes:    1,
+		},
+		{
+			name:              "test incorrect target",
+			URL:               "example.com:0",
+			wantErrorCategory: ErrorCategoryTarget,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := (&Checker{}).Audit(context.Background(), tt.URL)
+			if report.ErrorCategory != tt.wantErrorCategory {
+				t.Fatalf("Audit() error category = %v (%v), want %v", report.ErrorCategory, report.Err, tt.wantErrorCategory)
+			}
+			if !reflect.DeepEqual(report.Versions, tt.wantVersions) {
+				t.Errorf("Audit() versions = %v, want %v", report.Versions, tt.wantVersions)
+			}
+			if gotFindings := findingsCodes(report.Findings); !reflect.DeepEqual(gotFindings, tt.wantFindings) {
+				t.Errorf("Audit() findings = %v, want %v", gotFindings, tt.wantFindings)
+			}
+			if tt.wantErrorCategory == ErrorCategoryNone && !reflect.DeepEqual(report.NotTested, auditNotTested) {
+				t.Errorf("Audit() not tested = %v, want %v", report.NotTested, auditNotTested)
+			}
+			if report.Handshakes != tt.wantHandshakes {
+				t.Errorf("Audit() handshakes = %v, want %v", report.Handshakes, tt.wantHandshakes)
+			}
+			if report.Duration == 0 {
+				t.Errorf("Audit() duration is not set")
+			}
+		})
+	}
+}
+
+func Test_cipherSuiteWeakness(t *testing.T) {
+	tests := []struct {
+		name     string
+		suite    string
+		wantWeak bool
+	}{
+		{name: "test ECDHE suite", suite: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+		{name: "test TLS 1.3 suite", suite: "TLS_AES_256_GCM_SHA384"},
+		{name: "test TLS 1.3 ChaCha20 suite", suite: "TLS_CHACHA20_POLY1305_SHA256"},
+		{name: "test RSA key exchange", suite: "TLS_RSA_WITH_AES_128_GCM_SHA256", wantWeak: true},
+		{name: "test insecure suite", suite: "TLS_ECDHE_RSA_WITH_RC4_128_SHA", wantWeak: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cipherSuiteWeakness(tt.suite); (got != "") != tt.wantWeak {
+				t.Errorf("cipherSuiteWeakness() = %v, want weak %v", got, tt.wantWeak)
+			}
+		})
+	}
+}
+
+func TestAuditRegressions(t *testing.T) {
+	previous := AuditSummary{
+		Versions:         []string{"TLS 1.2", "TLS 1.3"},
+		WeakCipherSuites: []string{"TLS_RSA_WITH_AES_128_GCM_SHA256"},
+	}
+	tests := []struct {
+		name    string
+		current AuditSummary
+		want    []string
+	}{
+		{
+			name:    "test same result",
+			current: previous,
+		},
+		{
+			name:    "test better result",
+			current: AuditSummary{Versions: []string{"TLS 1.2", "TLS 1.3"}},
+		},
+		{
+			name: "test worse result",
+			current: AuditSummary{
+				Versions:         []string{"TLS 1.1", "TLS 1.2"},
+				WeakCipherSuites: []string{"TLS_RSA_WITH_AES_128_GCM_SHA256", "TLS_RSA_WITH_AES_256_CBC_SHA"},
+			},
+			want: []string{
+				"weak protocol TLS 1.1 is accepted now",
+				"protocol TLS 1.3 is not supported anymore",
+				"weak cipher suite TLS_RSA_WITH_AES_256_CBC_SHA is accepted now",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AuditRegressions(previous, tt.current); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AuditRegressions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package certinfo
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"golang.org/x/net/dns/dnsmessage"
+	"net"
+	"strings"
+)
+
+//CAA findings
+const (
+	FindingCAAMissing  FindingCode = "caa_missing"
+	FindingCAAMismatch FindingCode = "caa_mismatch"
+	FindingCAAError    FindingCode = "caa_error"
+)
+
+//typeCAA - DNS type of CAA records (RFC 8659)
+const typeCAA dnsmessage.Type = 257
+
+//caaIssuerDomains - CAA issuer domain names of known CAs by issuer organization
+var caaIssuerDomains = map[string][]string{
+	"let's encrypt":                {"letsencrypt.org"},
+	"digicert inc":                 {"digicert.com", "symantec.com", "geotrust.com", "rapidssl.com", "thawte.com"},
+	"sectigo limited":              {"sectigo.com", "comodoca.com", "comodo.com"},
+	"zerossl":                      {"sectigo.com", "zerossl.com"},
+	"google trust services llc":    {"pki.goog"},
+	"google trust services":        {"pki.goog"},
+	"amazon":                       {"amazon.com", "amazontrust.com", "awstrust.com", "amazonaws.com"},
+	"globalsign nv-sa":             {"globalsign.com"},
+	"godaddy.com, inc.":            {"godaddy.com", "starfieldtech.com"},
+	"starfield technologies, inc.": {"starfieldtech.com", "godaddy.com"},
+	"entrust, inc.":                {"entrust.net"},
+	"microsoft corporation":        {"microsoft.com"},
+	"buypass as-983163327":         {"buypass.com", "buypass.no"},
+	"ssl corporation":              {"ssl.com"},
+	"asseco data systems s.a.":     {"certum.pl", "certum.eu"},
+}
+
+//CAARecord single CAA record
+type CAARecord struct {
+	Flag  uint8  `json:"flag"`
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+//CAAResolver resolves CAA records of domain name
+//Returns empty list without error if domain name has no CAA records or does not exist
+type CAAResolver interface {
+	LookupCAA(ctx context.Context, domain string) ([]CAARecord, error)
+}
+
+//CAAReport result of CAA check of target host
+type CAAReport struct {
+	//Domain - domain name, where relevant CAA records are found. Empty if there are no CAA records
+	Domain string `json:"domain,omitempty"`
+	//Issuers - CAA issuer domain names permitted to issue certificate of target host
+	Issuers []string `json:"issuers,omitempty"`
+	//Wildcard - issuewild records are used, because host is covered by wildcard name
+	Wildcard bool `json:"wildcard,omitempty"`
+	//Authorized - issuer of served certificate is permitted by CAA records
+	Authorized bool `json:"authorized"`
+}
+
+//CheckCAA finds relevant CAA records of host walking up domain tree and checks that issuer of leaf certificate is permitted
+//Mismatch is critical if no CA is permitted or issuer is known CA, mismatch of unknown CA is warning
+//Returns nil report if CAAResolver is not set or host is IP address
+func (c *Checker) CheckCAA(ctx context.Context, host string, leaf *x509.Certificate) (*CAAReport, []Finding) {
+	if c.CAAResolver == nil || net.ParseIP(host) != nil {
+		return nil, nil
+	}
+
+	domain, records, err := c.relevantCAA(ctx, host)
+	if err != nil {
+		return nil, []Finding{{
+			Code:     FindingCAAError,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("cannot check CAA records of %s - %v", host, err),
+		}}
+	}
+	if len(records) == 0 {
+		return &CAAReport{Authorized: true}, []Finding{{
+			Code:     FindingCAAMissing,
+			Severity: SeverityInfo,
+			Message:  fmt.Sprintf("domain %s has no CAA records, any CA can issue certificate", host),
+		}}
+	}
+
+	report := &CAAReport{Domain: domain, Wildcard: coveredByWildcard(leaf, host)}
+	report.Issuers = caaIssuers(records, report.Wildcard)
+	issuer := issuerName(leaf)
+	for _, permitted := range report.Issuers {
+		if issuerMatchesCAA(leaf, permitted) {
+			report.Authorized = true
+			return report, nil
+		}
+	}
+
+	permitted := strings.Join(report.Issuers, ", ")
+	if permitted == "" {
+		permitted = "none"
+	}
+	finding := Finding{
+		Code:     FindingCAAMismatch,
+		Severity: SeverityCritical,
+		Message:  fmt.Sprintf("certificate issuer %s is not permitted by CAA records of %s (permitted: %s)", issuer, domain, permitted),
+	}
+	//issuer domain names of unknown CA are guessed by issuer name, mismatch is not certain
+	if len(report.Issuers) > 0 && !knownCAAIssuer(leaf) {
+		finding.Severity = SeverityWarning
+		finding.Message += ", CAA issuer domain name of CA is unknown"
+	}
+	return report, []Finding{finding}
+}
+
+//relevantCAA returns first non-empty CAA record set walking up from host to top level domain (RFC 8659, 3)
+func (c *Checker) relevantCAA(ctx context.Context, host string) (string, []CAARecord, error) {
+	domain := strings.TrimSuffix(strings.ToLower(host), ".")
+	for domain != "" {
+		records, err := c.CAAResolver.LookupCAA(ctx, domain)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(records) > 0 {
+			return domain, records, nil
+		}
+		index := strings.Index(domain, ".")
+		if index == -1 {
+			break
+		}
+		domain = domain[index+1:]
+	}
+	return "", nil, nil
+}
+
+//coveredByWildcard returns true if host is not listed in certificate names and is covered by wildcard name
+func coveredByWildcard(leaf *x509.Certificate, host string) bool {
+	host = strings.ToLower(host)
+	for _, name := range leaf.DNSNames {
+		if strings.ToLower(name) == host {
+			return false
+		}
+	}
+	for _, name := range leaf.DNSNames {
+		if strings.HasPrefix(name, "*.") && strings.HasSuffix(host, strings.ToLower(name[1:])) &&
+			!strings.Contains(strings.TrimSuffix(host, strings.ToLower(name[1:])), ".") {
+			return true
+		}
+	}
+	return false
+}
+
+//caaIssuers returns permitted issuer domain names from issue records, or from issuewild records for wildcard certificates
+//If there are no issuewild records, issue records are used for wildcard certificates too
+func caaIssuers(records []CAARecord, wildcard bool) []string {
+	tag := "issue"
+	if wildcard {
+		for _, record := range records {
+			if strings.EqualFold(record.Tag, "issuewild") {
+				tag = "issuewild"
+				break
+			}
+		}
+	}
+	var issuers []string
+	for _, record := range records {
+		if !strings.EqualFold(record.Tag, tag) {
+			continue
+		}
+		//issuer domain name is followed by optional parameters
+		issuer := strings.ToLower(strings.TrimSpace(strings.SplitN(record.Value, ";", 2)[0]))
+		if issuer != "" && !stringInSlice(issuer, issuers) {
+			issuers = append(issuers, issuer)
+		}
+	}
+	return issuers
+}
+
+//issuerName returns organization of certificate issuer, or common name if organization is empty
+func issuerName(leaf *x509.Certificate) string {
+	if len(leaf.Issuer.Organization) > 0 {
+		return leaf.Issuer.Organization[0]
+	}
+	return leaf.Issuer.CommonName
+}
+
+//knownCAAIssuer returns true if CAA issuer domain names of certificate issuer are known
+func knownCAAIssuer(leaf *x509.Certificate) bool {
+	_, ok := caaIssuerDomains[strings.ToLower(issuerName(leaf))]
+	return ok
+}
+
+//issuerMatchesCAA checks that CAA issuer domain name belongs to CA of certificate issuer
+//Known CAs are matched by issuer organization, other CAs - by second level label of issuer domain name in issuer name
+func issuerMatchesCAA(leaf *x509.Certificate, caaIssuer string) bool {
+	name := strings.ToLower(issuerName(leaf))
+	if domains, ok := caaIssuerDomains[name]; ok {
+		return stringInSlice(caaIssuer, domains)
+	}
+	labels := strings.Split(caaIssuer, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	label := labels[len(labels)-2]
+	return label != "" && strings.Contains(alphanumeric(name), alphanumeric(label))
+}
+
+//alphanumeric returns only letters and digits of string
+func alphanumeric(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, strings.ToLower(s))
+}
+
+//LookupCAA queries CAA records of domain name
+func (r *DNSResolver) LookupCAA(ctx context.Context, domain string) ([]CAARecord, error) {
+	answers, _, err := r.lookup(ctx, domain, typeCAA)
+	if err != nil {
+		return nil, err
+	}
+	var records []CAARecord
+	for _, data := range answers {
+		record, err := parseCAARecord(data)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+//parseCAARecord parses CAA record data: flag, tag length, tag and value (RFC 8659, 4.1)
+func parseCAARecord(data []byte) (CAARecord, error) {
+	if len(data) < 2 || len(data) < 2+int(data[1]) || data[1] == 0 {
+		return CAARecord{}, errors.New("dns resolver error - malformed CAA record")
+	}
+	tagEnd := 2 + int(data[1])
+	return CAARecord{Flag: data[0], Tag: string(data[2:tagEnd]), Value: string(data[tagEnd:])}, nil
+}
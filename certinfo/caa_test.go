@@ -0,0 +1,256 @@
+package certinfo
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"golang.org/x/net/dns/dnsmessage"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//testCAAResolver resolves CAA records from map, domains from errors map return error
+type testCAAResolver struct {
+	records map[string][]CAARecord
+	errors  map[string]error
+}
+
+func (r *testCAAResolver) LookupCAA(_ context.Context, domain string) ([]CAARecord, error) {
+	if err, ok := r.errors[domain]; ok {
+		return nil, err
+	}
+	return r.records[domain], nil
+}
+
+//newCAATestLeaf creates leaf certificate for dnsNames issued by CA with organization
+func newCAATestLeaf(t *testing.T, issuerOrganization string, dnsNames ...string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA", Organization: []string{issuerOrganization}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %v", err)
+	}
+	return cert
+}
+
+func TestChecker_CheckCAA(t *testing.T) {
+	letsEncryptLeaf := newCAATestLeaf(t, "Let's Encrypt", "www.example.com")
+	digiCertLeaf := newCAATestLeaf(t, "DigiCert Inc", "www.example.com")
+	wildcardLeaf := newCAATestLeaf(t, "DigiCert Inc", "*.example.com")
+	unknownCALeaf := newCAATestLeaf(t, "Example Trust Corp", "www.example.com")
+	records := map[string][]CAARecord{
+		"example.com": {
+			{Tag: "issue", Value: "letsencrypt.org"},
+			{Tag: "issuewild", Value: "digicert.com; cansignhttpexchanges=yes"},
+			{Tag: "iodef", Value: "mailto:security@example.com"},
+		},
+		"example.org":         {{Tag: "issue", Value: ";"}},
+		"trust.example.net":   {{Tag: "issue", Value: "exampletrust.com"}},
+		"www.example.net":     {{Tag: "issue", Value: "letsencrypt.org"}},
+		"sub.www.example.net": nil,
+	}
+
+	tests := []struct {
+		name             string
+		resolver         CAAResolver
+		host             string
+		leaf             *x509.Certificate
+		wantReport       *CAAReport
+		wantFindingCodes []FindingCode
+		wantSeverity     Severity
+	}{
+		{
+			name:       "test permitted issuer",
+			resolver:   &testCAAResolver{records: records},
+			host:       "www.example.com",
+			leaf:       letsEncryptLeaf,
+			wantReport: &CAAReport{Domain: "example.com", Issuers: []string{"letsencrypt.org"}, Authorized: true},
+		},
+		{
+			name:             "test not permitted issuer",
+			resolver:         &testCAAResolver{records: records},
+			host:             "www.example.com",
+			leaf:             digiCertLeaf,
+			wantReport:       &CAAReport{Domain: "example.com", Issuers: []string{"letsencrypt.org"}},
+			wantFindingCodes: []FindingCode{FindingCAAMismatch},
+			wantSeverity:     SeverityCritical,
+		},
+		{
+			name:       "test wildcard certificate",
+			resolver:   &testCAAResolver{records: records},
+			host:       "www.example.com",
+			leaf:       wildcardLeaf,
+			wantReport: &CAAReport{Domain: "example.com", Issuers: []string{"digicert.com"}, Wildcard: true, Authorized: true},
+		},
+		{
+			name:             "test no CA is permitted",
+			resolver:         &testCAAResolver{records: records},
+			host:             "example.org",
+			leaf:             letsEncryptLeaf,
+			wantReport:       &CAAReport{Domain: "example.org"},
+			wantFindingCodes: []FindingCode{FindingCAAMismatch},
+			wantSeverity:     SeverityCritical,
+		},
+		{
+			name:       "test unknown CA",
+			resolver:   &testCAAResolver{records: records},
+			host:       "trust.example.net",
+			leaf:       unknownCALeaf,
+			wantReport: &CAAReport{Domain: "trust.example.net", Issuers: []string{"exampletrust.com"}, Authorized: true},
+		},
+		{
+			name:             "test unknown CA is not permitted",
+			resolver:         &testCAAResolver{records: records},
+			host:             "www.example.com",
+			leaf:             unknownCALeaf,
+			wantReport:       &CAAReport{Domain: "example.com", Issuers: []string{"letsencrypt.org"}},
+			wantFindingCodes: []FindingCode{FindingCAAMismatch},
+			wantSeverity:     SeverityWarning,
+		},
+		{
+			name:       "test records of parent domain",
+			resolver:   &testCAAResolver{records: records},
+			host:       "sub.www.example.net",
+			leaf:       letsEncryptLeaf,
+			wantReport: &CAAReport{Domain: "www.example.net", Issuers: []string{"letsencrypt.org"}, Authorized: true},
+		},
+		{
+			name:             "test missing CAA records",
+			resolver:         &testCAAResolver{records: records},
+			host:             "www.example.info",
+			leaf:             digiCertLeaf,
+			wantReport:       &CAAReport{Authorized: true},
+			wantFindingCodes: []FindingCode{FindingCAAMissing},
+		},
+		{
+			name:             "test resolver error",
+			resolver:         &testCAAResolver{records: records, errors: map[string]error{"example.com": errors.New("server failure")}},
+			host:             "www.example.com",
+			leaf:             letsEncryptLeaf,
+			wantFindingCodes: []FindingCode{FindingCAAError},
+		},
+		{
+			name:     "test IP address",
+			resolver: &testCAAResolver{records: records},
+			host:     "127.0.0.1",
+			leaf:     letsEncryptLeaf,
+		},
+		{
+			name: "test resolver is not set",
+			host: "www.example.com",
+			leaf: letsEncryptLeaf,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := &Checker{CAAResolver: tt.resolver}
+			report, findings := checker.CheckCAA(context.Background(), tt.host, tt.leaf)
+			if !reflect.DeepEqual(report, tt.wantReport) {
+				t.Errorf("CheckCAA() report = %+v, want %+v", report, tt.wantReport)
+			}
+			if got := findingsCodes(findings); !reflect.DeepEqual(got, tt.wantFindingCodes) {
+				t.Errorf("CheckCAA() findings = %v, want %v", got, tt.wantFindingCodes)
+			}
+			if tt.wantSeverity != "" && (len(findings) == 0 || findings[0].Severity != tt.wantSeverity) {
+				t.Errorf("CheckCAA() findings = %v, want severity %v", findings, tt.wantSeverity)
+			}
+		})
+	}
+}
+
+//caaResource returns DNS resource of CAA record
+func caaResource(record CAARecord) dnsmessage.UnknownResource {
+	data := append([]byte{record.Flag, byte(len(record.Tag))}, record.Tag...)
+	return dnsmessage.UnknownResource{Type: typeCAA, Data: append(data, record.Value...)}
+}
+
+func TestDNSResolver_LookupCAA(t *testing.T) {
+	server := &testDNSServer{
+		records: map[string][]dnsmessage.UnknownResource{
+			"example.com": {
+				caaResource(CAARecord{Tag: "issue", Value: "letsencrypt.org"}),
+				caaResource(CAARecord{Flag: 128, Tag: "issuewild", Value: ";"}),
+			},
+			"large.example.com": {caaResource(CAARecord{Tag: "issue", Value: "digicert.com"})},
+		},
+		rcodes: map[string]dnsmessage.RCode{
+			"missing.example.com": dnsmessage.RCodeNameError,
+			"broken.example.com":  dnsmessage.RCodeServerFailure,
+		},
+		truncate: map[string]bool{"large.example.com": true},
+	}
+	resolver, err := NewDNSResolver(server.start(t))
+	if err != nil {
+		t.Fatalf("NewDNSResolver() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		domain  string
+		want    []CAARecord
+		wantErr bool
+	}{
+		{
+			name:   "test CAA records",
+			domain: "example.com",
+			want:   []CAARecord{{Tag: "issue", Value: "letsencrypt.org"}, {Flag: 128, Tag: "issuewild", Value: ";"}},
+		},
+		{
+			name:   "test truncated response",
+			domain: "large.example.com",
+			want:   []CAARecord{{Tag: "issue", Value: "digicert.com"}},
+		},
+		{
+			name:   "test domain without records",
+			domain: "www.example.com",
+		},
+		{
+			name:   "test not existing domain",
+			domain: "missing.example.com",
+		},
+		{
+			name:    "test server failure",
+			domain:  "broken.example.com",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolver.LookupCAA(context.Background(), tt.domain)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LookupCAA() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LookupCAA() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
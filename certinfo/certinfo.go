@@ -1,51 +1,325 @@
 package certinfo
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
-	"log"
+	"net"
+	"net/http"
 	"strings"
+	"sync"
+	"time"
 )
 
-func GetCertsInfo(URLs string, printFullChain bool) string {
-	UrlArr := strings.Split(URLs, " ")
+const (
+	DefaultConnectTimeout   = 10 * time.Second
+	DefaultHandshakeTimeout = 10 * time.Second
+)
+
+//DefaultMaxParallelChecks - default max count of targets checked concurrently by CheckAll
+const DefaultMaxParallelChecks = 8
+
+//Checker certificates checker
+//Zero value Checker is ready to use with default timeouts
+type Checker struct {
+	//ConnectTimeout - timeout of DNS resolving and TCP connect. If 0 - DefaultConnectTimeout is used
+	ConnectTimeout time.Duration
+	//HandshakeTimeout - timeout of STARTTLS upgrade and TLS handshake. If 0 - DefaultHandshakeTimeout is used
+	HandshakeTimeout time.Duration
+	//Resolver - resolver of target hosts. If nil - net.DefaultResolver is used
+	Resolver Resolver
+	//Proxy - outbound proxy for connections to checked hosts. If nil - hosts are dialed directly
+	Proxy *Proxy
+	//KeyPolicy - thresholds of key, signature algorithm and validity period checks. Zero fields are set to defaults
+	KeyPolicy KeyPolicy
+	//HTTPClient - client of requests to OCSP responders, CRL distribution points and AIA URLs
+	//If nil - client with DefaultHTTPTimeout is used, requests are sent through Proxy
+	HTTPClient *http.Client
+	//CRLCache - cache of downloaded CRLs. If nil - CRLs are downloaded on every check
+	CRLCache CRLCache
+	//CTLogs - known Certificate Transparency logs. If nil - SCTs are not checked
+	CTLogs *CTLogList
+	//CAAResolver - resolver of CAA records of target hosts. If nil - CAA records are not checked
+	CAAResolver CAAResolver
+	//TLSAResolver - resolver of TLSA records of target endpoints. If nil - DANE is not checked
+	TLSAResolver TLSAResolver
+	//MaxParallelChecks - max count of targets checked concurrently by CheckAll. If 0 - DefaultMaxParallelChecks is used
+	MaxParallelChecks int
+	//ClientCertificate - client certificate for mutual TLS, sent if server requests it and certificate is allowed for host. If nil - client certificate is not sent
+	ClientCertificate *ClientCertificate
+	//Roots - trusted roots of chain verification, for example roots of internal PKI. If nil - system roots are used
+	Roots *x509.CertPool
+}
+
+//GetCertsInfo checks space separated targets with default Checker and returns reports formatted for Telegram
+func GetCertsInfo(ctx context.Context, URLs string, printFullChain bool) string {
+	return (&Checker{}).GetCertsInfo(ctx, URLs, printFullChain)
+}
+
+//Check checks certificates on URL with default Checker
+func Check(ctx context.Context, URL string) *CertReport {
+	return (&Checker{}).Check(ctx, URL)
+}
+
+//CheckTarget checks certificates on target with default Checker
+func CheckTarget(ctx context.Context, target *Target) *CertReport {
+	return (&Checker{}).CheckTarget(ctx, target)
+}
+
+//WithProxy returns copy of Checker with outbound proxy. If proxy is nil - hosts are dialed directly
+func (c *Checker) WithProxy(proxy *Proxy) *Checker {
+	checker := *c
+	checker.Proxy = proxy
+	return &checker
+}
+
+//WithRoots returns copy of Checker with trusted roots. If roots is nil - system roots are used
+func (c *Checker) WithRoots(roots *x509.CertPool) *Checker {
+	checker := *c
+	checker.Roots = roots
+	return &checker
+}
+
+//WithClientCertificate returns copy of Checker with client certificate for mutual TLS. If cert is nil - client certificate is not sent
+func (c *Checker) WithClientCertificate(cert *ClientCertificate) *Checker {
+	checker := *c
+	checker.ClientCertificate = cert
+	return &checker
+}
+
+//GetCertsInfo checks space separated targets concurrently and returns reports formatted for Telegram in order of targets
+func (c *Checker) GetCertsInfo(ctx context.Context, URLs string, printFullChain bool) string {
 	result := ""
-	for _, url := range UrlArr {
-		certStr, _, err := GetCertInfo(url, printFullChain)
-		if err != nil {
-			result += err.Error()
-		}
-		result += certStr
+	for _, report := range c.CheckAll(ctx, strings.Split(URLs, " ")) {
+		result += FormatTelegram(report, printFullChain)
 	}
 	return result
 }
 
-func GetCertInfo(URL string, printFullChain bool) (string, []*x509.Certificate, error) {
-	conf := &tls.Config{
-		InsecureSkipVerify: true,
+//CheckAll checks URLs concurrently, at most MaxParallelChecks URLs at once
+//Reports are returned in order of URLs, failed or slow URL does not affect reports of other URLs
+func (c *Checker) CheckAll(ctx context.Context, URLs []string) []*CertReport {
+	reports := make([]*CertReport, len(URLs))
+	workers := make(chan struct{}, c.maxParallelChecks())
+	var wg sync.WaitGroup
+	for i, URL := range URLs {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(i int, URL string) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			reports[i] = c.Check(ctx, URL)
+		}(i, URL)
 	}
+	wg.Wait()
+	return reports
+}
 
-	conn, err := tls.Dial("tcp", URL+":443", conf)
+//Check checks certificates on URL
+//URL - target in host, host:port, IPv4 or [IPv6]:port format. If port is not specified - used 443
+//URL can be prefixed with STARTTLS protocol, for example: smtp://mx.example.com:25
+//Use host@IP format to connect to IP address with host as SNI, for example: shop.example.com@10.0.0.5:443
+//Every resolved IP address of host is checked, differences between them are reported as findings
+//Errors are returned in report
+func (c *Checker) Check(ctx context.Context, URL string) *CertReport {
+	target, err := ParseTarget(URL)
 	if err != nil {
-		log.Println("Error in Dial", err)
-		return "", nil, fmt.Errorf("check certificate error - cannot check cert from URL %s. Error: %e\n\n", URL, err)
+		report := &CertReport{
+			Target:    URL,
+			StartedAt: time.Now(),
+		}
+		report.setError(ErrorCategoryTarget, err)
+		report.Duration = time.Since(report.StartedAt)
+		return report
 	}
-	defer conn.Close()
-	certs := conn.ConnectionState().PeerCertificates
-	result := ""
-	var certsResult []*x509.Certificate
-	for _, cert := range certs {
-		if !printFullChain && cert.IsCA {
-			continue
+	return c.CheckTarget(ctx, target)
+}
+
+//CheckTarget checks certificates on target
+//If target IP is specified - only this IP address is checked, target host is used as SNI and for verification
+//If target port is empty - default port of target protocol is used
+//Errors are returned in report
+func (c *Checker) CheckTarget(ctx context.Context, target *Target) *CertReport {
+	checkedTarget := *target
+	if checkedTarget.Port == "" {
+		checkedTarget.Port = defaultTargetPort(checkedTarget.Protocol)
+	}
+	report := &CertReport{
+		Target:    checkedTarget.String(),
+		Endpoint:  checkedTarget.Address(),
+		StartedAt: time.Now(),
+	}
+	defer func() {
+		report.Duration = time.Since(report.StartedAt)
+	}()
+
+	c.checkNodesTarget(ctx, &checkedTarget, report)
+
+	return report
+}
+
+func (c *Checker) maxParallelChecks() int {
+	if c.MaxParallelChecks > 0 {
+		return c.MaxParallelChecks
+	}
+	return DefaultMaxParallelChecks
+}
+
+func (c *Checker) connectTimeout() time.Duration {
+	if c.ConnectTimeout > 0 {
+		return c.ConnectTimeout
+	}
+	return DefaultConnectTimeout
+}
+
+func (c *Checker) handshakeTimeout() time.Duration {
+	if c.HandshakeTimeout > 0 {
+		return c.HandshakeTimeout
+	}
+	return DefaultHandshakeTimeout
+}
+
+//dialTarget connects to address of target, runs STARTTLS upgrade if target protocol is specified and makes TLS handshake
+//conf - TLS config of handshake, can be nil. Certificates are not verified while handshake, target host is used as SNI
+//Returns *checkError on error
+func (c *Checker) dialTarget(ctx context.Context, target *Target, address string, conf *tls.Config) (*tls.Conn, error) {
+	dialer := net.Dialer{Timeout: c.connectTimeout()}
+	var rawConn net.Conn
+	var err error
+	host, _, _ := net.SplitHostPort(address)
+	if proxy := c.proxyFor(target.Host, host); proxy != nil {
+		rawConn, err = proxy.dialContext(ctx, &dialer, address)
+		if err != nil {
+			return nil, dialError(ctx, err, ErrorCategoryProxy, "connecting to "+address+" through proxy "+proxy.Address)
+		}
+	} else {
+		rawConn, err = dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return nil, dialError(ctx, err, ErrorCategoryConnect, "connecting to "+address)
+		}
+	}
+
+	//close connection on context cancellation, deadline limits STARTTLS upgrade and handshake
+	_ = rawConn.SetDeadline(time.Now().Add(c.handshakeTimeout()))
+	handshakeDone := make(chan struct{})
+	defer close(handshakeDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = rawConn.SetDeadline(time.Now())
+		case <-handshakeDone:
+		}
+	}()
+
+	err = startTLS(rawConn, target.Protocol, target.Host)
+	if err != nil {
+		_ = rawConn.Close()
+		return nil, dialError(ctx, err, ErrorCategorySTARTTLS, target.Protocol+" STARTTLS upgrade")
+	}
+
+	if conf == nil {
+		conf = &tls.Config{}
+	} else {
+		conf = conf.Clone()
+	}
+	conf.InsecureSkipVerify = true
+	conf.ServerName = target.Host
+	if c.ClientCertificate != nil && c.ClientCertificate.AllowedFor(target.Host) {
+		//certificate is not sent, if it is not issued by CA accepted by server
+		clientCertificate := c.ClientCertificate
+		conf.GetClientCertificate = func(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if !clientCertificate.acceptedBy(info.AcceptableCAs) {
+				return &tls.Certificate{}, nil
+			}
+			return &clientCertificate.Certificate, nil
+		}
+	}
+	conn := tls.Client(rawConn, conf)
+	err = conn.HandshakeContext(ctx)
+	if err != nil {
+		_ = rawConn.Close()
+		return nil, dialError(ctx, err, ErrorCategoryHandshake, "TLS handshake")
+	}
+	_ = rawConn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+//proxyFor returns proxy for connection to host on IP address, nil if host must be dialed directly
+func (c *Checker) proxyFor(host string, ip string) *Proxy {
+	if c.Proxy == nil || c.Proxy.bypass(host, ip) {
+		return nil
+	}
+	return c.Proxy
+}
+
+//proxyDialContext returns dial function of HTTP transport, which connects through proxy
+//Host names are resolved locally, except socks5h proxy. Hosts from no proxy list are dialed directly
+func (c *Checker) proxyDialContext(proxy *Proxy) func(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: c.connectTimeout()}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		ip := ""
+		if !proxy.resolvesRemotely() && net.ParseIP(host) == nil {
+			addrs, err := c.resolver().LookupIPAddr(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+			if len(addrs) == 0 {
+				return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+			}
+			ip = addrs[0].IP.String()
+			address = net.JoinHostPort(ip, port)
 		}
-		certsResult = append(certsResult, cert)
-		result += fmt.Sprintf("✅ Check certificate for domain: %s\n", URL)
-		result += fmt.Sprintf("DNSNames: %s\n", cert.DNSNames)
-		result += fmt.Sprintf("Issuer Name: %s\n", cert.Issuer)
-		result += fmt.Sprintf("Expiry: %s\n", cert.NotAfter.Format("2006-01-02"))
-		result += fmt.Sprintf("Common Name: %s\n", cert.Issuer.CommonName)
+		if proxy.bypass(host, ip) {
+			return dialer.DialContext(ctx, network, address)
+		}
+		return proxy.dialContext(ctx, dialer, address)
+	}
+}
+
+//dialError returns categorized error with clear message for timeouts and cancellation
+//stage - name of check stage, where error is happened
+func dialError(ctx context.Context, err error, defaultCategory ErrorCategory, stage string) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return &checkError{category: ErrorCategoryCanceled, err: fmt.Errorf("check canceled while %s", stage)}
+	}
+	category := dialErrorCategory(err, defaultCategory)
+	if category == ErrorCategoryTimeout || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &checkError{category: ErrorCategoryTimeout, err: fmt.Errorf("timed out while %s (%w)", stage, err)}
+	}
+	return &checkError{category: category, err: err}
+}
+
+//dialErrorCategory returns category of network error, or defaultCategory if error is not recognized
+func dialErrorCategory(err error, defaultCategory ErrorCategory) ErrorCategory {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorCategoryTimeout
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorCategoryDNS
+	}
+	return defaultCategory
+}
 
+//tlsVersionName returns name of TLS version
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return "unknown"
 	}
-	return result + "\n", certsResult, nil
 }
@@ -1,11 +1,163 @@
 package certinfo
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
-func TestGetCertInfo(t *testing.T) {
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+type testCertOptions struct {
+	commonName string
+	dnsNames   []string
+	ips        []net.IP
+	isCA       bool
+	notBefore  time.Time
+	notAfter   time.Time
+	//ocspServers - OCSP responders in AIA extension
+	ocspServers []string
+	//crlServers - CRL distribution points
+	crlServers []string
+	//caIssuers - issuer certificate URLs in AIA extension
+	caIssuers []string
+}
+
+//newTestCert creates certificate signed by parent. If parent is nil - creates self-signed certificate
+func newTestCert(t *testing.T, opts testCertOptions, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	if opts.notBefore.IsZero() {
+		opts.notBefore = time.Now().Add(-time.Hour)
+	}
+	if opts.notAfter.IsZero() {
+		opts.notAfter = time.Now().Add(24 * time.Hour * 90)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("cannot generate serial: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: opts.commonName},
+		DNSNames:              opts.dnsNames,
+		IPAddresses:           opts.ips,
+		NotBefore:             opts.notBefore,
+		NotAfter:              opts.notAfter,
+		BasicConstraintsValid: true,
+		IsCA:                  opts.isCA,
+		OCSPServer:            opts.ocspServers,
+		CRLDistributionPoints: opts.crlServers,
+		IssuingCertificateURL: opts.caIssuers,
+	}
+	if opts.isCA {
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	} else {
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %v", err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+//newTestChain creates leaf certificate for dnsNames signed by intermediate and root CA
+func newTestChain(t *testing.T, dnsNames ...string) (leaf, intermediate, root *testCert) {
+	caNotBefore := time.Now().Add(-24 * time.Hour * 365)
+	caNotAfter := time.Now().Add(24 * time.Hour * 365)
+	root = newTestCert(t, testCertOptions{commonName: "Test Root CA", isCA: true, notBefore: caNotBefore, notAfter: caNotAfter}, nil)
+	intermediate = newTestCert(t, testCertOptions{commonName: "Test Intermediate CA", isCA: true, notBefore: caNotBefore, notAfter: caNotAfter}, root)
+	leaf = newTestCert(t, testCertOptions{
+		commonName: dnsNames[0],
+		dnsNames:   dnsNames,
+		ips:        []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+	}, intermediate)
+	return leaf, intermediate, root
+}
+
+//newTestTLSConfig creates server tls config with chain. First certificate in chain must be a leaf
+func newTestTLSConfig(chain ...*testCert) *tls.Config {
+	certificate := tls.Certificate{PrivateKey: chain[0].key, Leaf: chain[0].cert}
+	for _, cert := range chain {
+		certificate.Certificate = append(certificate.Certificate, cert.cert.Raw)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{certificate}}
+}
+
+//startTestTLSServer starts local TLS server, returns server address
+func startTestTLSServer(t *testing.T, config *tls.Config) string {
+	return startTestTLSServerAt(t, config, "127.0.0.1:0")
+}
+
+//startTestTLSServerAt starts local TLS server on address, returns server address
+func startTestTLSServerAt(t *testing.T, config *tls.Config, address string) string {
+	listener, err := tls.Listen("tcp", address, config)
+	if err != nil {
+		t.Fatalf("cannot start test server: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				_ = conn.(*tls.Conn).Handshake()
+				_ = conn.Close()
+			}(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+//checkAndFormat checks URL, returns report formatted for Telegram, certificates shown in message and check error
+func checkAndFormat(URL string, printFullChain bool) (string, []*x509.Certificate, error) {
+	report := Check(context.Background(), URL)
+	if report.Err != nil {
+		return "", nil, errors.New(FormatTelegram(report, printFullChain))
+	}
+	var certs []*x509.Certificate
+	for _, cert := range report.Chain {
+		if !printFullChain && cert.IsCA {
+			continue
+		}
+		certs = append(certs, cert.Certificate)
+	}
+	return FormatTelegram(report, printFullChain), certs, nil
+}
+
+func TestCheck(t *testing.T) {
 	type args struct {
 		URL            string
 		printFullChain bool
@@ -25,7 +177,7 @@ func TestGetCertInfo(t *testing.T) {
 				printFullChain: false,
 			},
 			wantErr:    true,
-			errMessage: "check certificate error - cannot check cert from URL NotValidURL\\..*",
+			errMessage: "check certificate error - cannot check cert from URL notvalidurl\\..*",
 			certsCount: 0,
 		},
 		{
@@ -34,7 +186,7 @@ func TestGetCertInfo(t *testing.T) {
 				URL:            "google.com",
 				printFullChain: false,
 			},
-			want:       "DNSNames: .*google\\.com.*\nIssuer Name: .*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*\n\n",
+			want:       "DNSNames: .*google\\.com.*\nIssuer Name: .*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*\nKey: .*\n(OCSP: .*\n)?(CRL: .*\n)?\n",
 			certsCount: 1,
 		},
 		{
@@ -44,34 +196,79 @@ func TestGetCertInfo(t *testing.T) {
 				printFullChain: true,
 			},
 			want: "DNSNames: .*google\\.com.*\nIssuer Name: .*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: (.|\n)*" +
-				"DNSNames: \\[\\]\nIssuer Name: .*OU=Root CA.*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*Root.*\n\n",
+				"DNSNames: \\[\\]\nIssuer Name: .*OU=Root CA.*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*Root.*\nKey: .*\n(OCSP: .*\n)?(CRL: .*\n)?\n",
 			certsCount: 3,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, gotCerts, err := GetCertInfo(tt.args.URL, tt.args.printFullChain)
+			got, gotCerts, err := checkAndFormat(tt.args.URL, tt.args.printFullChain)
 			if tt.wantErr {
 				if err == nil {
-					t.Errorf("GetCertInfo() - expected Error, got nil")
+					t.Errorf("Check() - expected Error, got nil")
 				}
 				res, err2 := regexp.MatchString(tt.errMessage, err.Error())
 				if err2 != nil {
-					t.Errorf("GetCertInfo() - regex error: %s", err2)
+					t.Errorf("Check() - regex error: %s", err2)
 				}
 				if !res {
-					t.Errorf("GetCertInfo() = %v, regex pattern = %v", err, tt.errMessage)
+					t.Errorf("Check() = %v, regex pattern = %v", err, tt.errMessage)
 				}
 			}
 			if len(gotCerts) != tt.certsCount {
-				t.Errorf("GetCertInfo() incorrect certs count in array got %d, want %d", len(gotCerts), tt.certsCount)
+				t.Errorf("Check() incorrect certs count in array got %d, want %d", len(gotCerts), tt.certsCount)
 			}
 			res, err := regexp.MatchString(tt.want, got)
 			if err != nil {
-				t.Errorf("GetCertInfo() - regex error: %s", err)
+				t.Errorf("Check() - regex error: %s", err)
 			}
 			if !res {
-				t.Errorf("GetCertInfo() = %v, regex pattern = %v", got, tt.want)
+				t.Errorf("Check() = %v, regex pattern = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck_port(t *testing.T) {
+	leaf, intermediate, _ := newTestChain(t, "localhost")
+	address := startTestTLSServer(t, newTestTLSConfig(leaf, intermediate))
+	_, port, _ := net.SplitHostPort(address)
+
+	tests := []struct {
+		name       string
+		URL        string
+		want       string
+		certsCount int
+	}{
+		{
+			name:       "test IPv4 with port",
+			URL:        address,
+			want:       "⚠️ Check certificate for domain: 127\\.0\\.0\\.1:" + port + "\nDNSNames: \\[localhost\\]\n",
+			certsCount: 1,
+		},
+		{
+			name:       "test host with port",
+			URL:        "localhost:" + port,
+			want:       "⚠️ Check certificate for domain: localhost:" + port + "\nDNSNames: \\[localhost\\]\n",
+			certsCount: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotCerts, err := checkAndFormat(tt.URL, false)
+			if err != nil {
+				t.Errorf("Check() unexpected error: %v", err)
+				return
+			}
+			if len(gotCerts) != tt.certsCount {
+				t.Errorf("Check() incorrect certs count in array got %d, want %d", len(gotCerts), tt.certsCount)
+			}
+			res, err := regexp.MatchString(tt.want, got)
+			if err != nil {
+				t.Errorf("Check() - regex error: %s", err)
+			}
+			if !res {
+				t.Errorf("Check() = %v, regex pattern = %v", got, tt.want)
 			}
 		})
 	}
@@ -93,7 +290,7 @@ func TestGetCertsInfo(t *testing.T) {
 				URLs:           "google.com",
 				printFullChain: false,
 			},
-			want: "DNSNames: .*google\\.com.*\nIssuer Name: .*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*\n\n",
+			want: "DNSNames: .*google\\.com.*\nIssuer Name: .*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*\nKey: .*\n(OCSP: .*\n)?(CRL: .*\n)?\n",
 		},
 		{
 			name: "test few valid URLs",
@@ -101,9 +298,9 @@ func TestGetCertsInfo(t *testing.T) {
 				URLs:           "google.com github.com wikipedia.com",
 				printFullChain: false,
 			},
-			want: "✅ Check certificate for domain: .*\nDNSNames: .*google\\.com.*\nIssuer Name: .*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*\n\n" +
-				"✅ Check certificate for domain: .*\nDNSNames: .*github\\.com.*\nIssuer Name: .*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*\n\n" +
-				"✅ Check certificate for domain: .*\nDNSNames: .*wikipedia\\.com.*\nIssuer Name: .*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*\n\n",
+			want: "✅ Check certificate for domain: .*\nDNSNames: .*google\\.com.*\nIssuer Name: .*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*\nKey: .*\n(OCSP: .*\n)?(CRL: .*\n)?\n" +
+				"✅ Check certificate for domain: .*\nDNSNames: .*github\\.com.*\nIssuer Name: .*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*\nKey: .*\n(OCSP: .*\n)?(CRL: .*\n)?\n" +
+				"✅ Check certificate for domain: .*\nDNSNames: .*wikipedia\\.com.*\nIssuer Name: .*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*\nKey: .*\n(OCSP: .*\n)?(CRL: .*\n)?\n",
 		},
 		{
 			name: "test valid and fail URLs",
@@ -111,14 +308,14 @@ func TestGetCertsInfo(t *testing.T) {
 				URLs:           "google.com notValidDomain wikipedia.com",
 				printFullChain: false,
 			},
-			want: "✅ Check certificate for domain: .*\nDNSNames: .*google\\.com.*\nIssuer Name: .*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*\n\n" +
+			want: "✅ Check certificate for domain: .*\nDNSNames: .*google\\.com.*\nIssuer Name: .*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*\nKey: .*\n(OCSP: .*\n)?(CRL: .*\n)?\n" +
 				"check certificate error - cannot check cert from URL notValidDomain\\..*\n\n" +
-				"✅ Check certificate for domain: .*\nDNSNames: .*wikipedia\\.com.*\nIssuer Name: .*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*\n\n",
+				"✅ Check certificate for domain: .*\nDNSNames: .*wikipedia\\.com.*\nIssuer Name: .*\nExpiry: \\d\\d\\d\\d-\\d\\d-\\d\\d\nCommon Name: .*\nKey: .*\n(OCSP: .*\n)?(CRL: .*\n)?\n",
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := GetCertsInfo(tt.args.URLs, tt.args.printFullChain)
+			got := GetCertsInfo(context.Background(), tt.args.URLs, tt.args.printFullChain)
 			res, err := regexp.MatchString(tt.want, got)
 			if err != nil {
 				t.Errorf("GetCertsInfo() - regex error: %s", err)
@@ -129,3 +326,230 @@ func TestGetCertsInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestCheck_report(t *testing.T) {
+	leaf, intermediate, _ := newTestChain(t, "localhost")
+	address := startTestTLSServer(t, newTestTLSConfig(leaf, intermediate))
+
+	//get free port without listener
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedAddress := listener.Addr().String()
+	_ = listener.Close()
+
+	tests := []struct {
+		name              string
+		URL               string
+		wantIP            string
+		wantTLSVersion    string
+		wantChain         int
+		wantErrorCategory ErrorCategory
+	}{
+		{
+			name:           "test success report",
+			URL:            address,
+			wantIP:         "127.0.0.1",
+			wantTLSVersion: "TLS 1.3",
+			wantChain:      2,
+		},
+		{
+			name:              "test incorrect target",
+			URL:               "localhost:0",
+			wantErrorCategory: ErrorCategoryTarget,
+		},
+		{
+			name:              "test closed port",
+			URL:               closedAddress,
+			wantErrorCategory: ErrorCategoryConnect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Check(context.Background(), tt.URL)
+			if got.ErrorCategory != tt.wantErrorCategory {
+				t.Errorf("Check() error category = %v, want %v (error %v)", got.ErrorCategory, tt.wantErrorCategory, got.Err)
+			}
+			if (got.Err != nil) != (tt.wantErrorCategory != ErrorCategoryNone) {
+				t.Errorf("Check() unexpected error = %v", got.Err)
+			}
+			if got.IP != tt.wantIP {
+				t.Errorf("Check() IP = %v, want %v", got.IP, tt.wantIP)
+			}
+			if got.TLSVersion != tt.wantTLSVersion {
+				t.Errorf("Check() TLS version = %v, want %v", got.TLSVersion, tt.wantTLSVersion)
+			}
+			if len(got.Chain) != tt.wantChain {
+				t.Errorf("Check() chain length = %d, want %d", len(got.Chain), tt.wantChain)
+			}
+			for i, cert := range got.Chain {
+				if cert.Position != i || cert.Certificate == nil {
+					t.Errorf("Check() incorrect chain certificate %d: %v", i, cert)
+				}
+			}
+			if got.StartedAt.IsZero() || got.Duration <= 0 {
+				t.Errorf("Check() timing is not set: started %v, duration %v", got.StartedAt, got.Duration)
+			}
+		})
+	}
+}
+
+//startTestSilentServer starts local server, which accepts connections and never answers
+func startTestSilentServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot start test server: %v", err)
+	}
+	var conns []net.Conn
+	var mutex sync.Mutex
+	t.Cleanup(func() {
+		_ = listener.Close()
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mutex.Lock()
+			conns = append(conns, conn)
+			mutex.Unlock()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestChecker_Check_timeouts(t *testing.T) {
+	address := startTestSilentServer(t)
+
+	tests := []struct {
+		name              string
+		checker           *Checker
+		timeout           time.Duration
+		cancelAfter       time.Duration
+		wantErrorCategory ErrorCategory
+		wantErr           string
+	}{
+		{
+			name:              "test handshake timeout",
+			checker:           &Checker{HandshakeTimeout: 200 * time.Millisecond},
+			wantErrorCategory: ErrorCategoryTimeout,
+			wantErr:           "timed out while TLS handshake",
+		},
+		{
+			name:              "test STARTTLS timeout",
+			checker:           &Checker{HandshakeTimeout: 200 * time.Millisecond},
+			wantErrorCategory: ErrorCategoryTimeout,
+			wantErr:           "timed out while smtp STARTTLS upgrade",
+		},
+		{
+			name:              "test context deadline",
+			checker:           &Checker{},
+			timeout:           200 * time.Millisecond,
+			wantErrorCategory: ErrorCategoryTimeout,
+			wantErr:           "timed out while TLS handshake",
+		},
+		{
+			name:              "test context cancel",
+			checker:           &Checker{},
+			cancelAfter:       200 * time.Millisecond,
+			wantErrorCategory: ErrorCategoryCanceled,
+			wantErr:           "check canceled while TLS handshake",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			if tt.cancelAfter > 0 {
+				time.AfterFunc(tt.cancelAfter, cancel)
+			}
+			URL := address
+			if strings.Contains(tt.wantErr, "smtp") {
+				URL = "smtp://" + address
+			}
+
+			start := time.Now()
+			got := tt.checker.Check(ctx, URL)
+			if time.Since(start) > 5*time.Second {
+				t.Errorf("Check() is not interrupted in time, duration %v", time.Since(start))
+			}
+			if got.ErrorCategory != tt.wantErrorCategory {
+				t.Errorf("Check() error category = %v, want %v", got.ErrorCategory, tt.wantErrorCategory)
+			}
+			if got.Err == nil || !strings.HasPrefix(got.Err.Error(), tt.wantErr) {
+				t.Errorf("Check() error = %v, want %v", got.Err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChecker_CheckAll(t *testing.T) {
+	leaf, intermediate, _ := newTestChain(t, "example.com")
+	tlsAddress := startTestTLSServer(t, newTestTLSConfig(leaf, intermediate))
+	silentAddress := startTestSilentServer(t)
+	handshakeTimeout := 300 * time.Millisecond
+
+	tests := []struct {
+		name              string
+		maxParallelChecks int
+		URLs              []string
+		wantErrorCategory []ErrorCategory
+		minDuration       time.Duration
+		maxDuration       time.Duration
+	}{
+		{
+			name:              "test reports order",
+			maxParallelChecks: 4,
+			URLs:              []string{silentAddress, "example.com@" + tlsAddress, "example.com:0", silentAddress},
+			wantErrorCategory: []ErrorCategory{ErrorCategoryTimeout, ErrorCategoryNone, ErrorCategoryTarget, ErrorCategoryTimeout},
+			maxDuration:       2 * handshakeTimeout,
+		},
+		{
+			name:              "test slow targets are checked concurrently",
+			maxParallelChecks: 4,
+			URLs:              []string{silentAddress, silentAddress, silentAddress, silentAddress},
+			wantErrorCategory: []ErrorCategory{ErrorCategoryTimeout, ErrorCategoryTimeout, ErrorCategoryTimeout, ErrorCategoryTimeout},
+			maxDuration:       2 * handshakeTimeout,
+		},
+		{
+			name:              "test workers limit",
+			maxParallelChecks: 2,
+			URLs:              []string{silentAddress, silentAddress, silentAddress, silentAddress},
+			wantErrorCategory: []ErrorCategory{ErrorCategoryTimeout, ErrorCategoryTimeout, ErrorCategoryTimeout, ErrorCategoryTimeout},
+			minDuration:       2 * handshakeTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := &Checker{HandshakeTimeout: handshakeTimeout, MaxParallelChecks: tt.maxParallelChecks}
+			start := time.Now()
+			reports := checker.CheckAll(context.Background(), tt.URLs)
+			duration := time.Since(start)
+			if len(reports) != len(tt.URLs) {
+				t.Fatalf("CheckAll() reports count = %d, want %d", len(reports), len(tt.URLs))
+			}
+			for i, report := range reports {
+				if report.ErrorCategory != tt.wantErrorCategory[i] {
+					t.Errorf("CheckAll() report %d error category = %v, want %v (%v)", i, report.ErrorCategory, tt.wantErrorCategory[i], report.Err)
+				}
+				if !strings.HasPrefix(report.Target, strings.Split(tt.URLs[i], ":")[0]) {
+					t.Errorf("CheckAll() report %d target = %v, want %v", i, report.Target, tt.URLs[i])
+				}
+			}
+			if duration < tt.minDuration {
+				t.Errorf("CheckAll() duration = %v, want at least %v", duration, tt.minDuration)
+			}
+			if tt.maxDuration > 0 && duration > tt.maxDuration {
+				t.Errorf("CheckAll() duration = %v, want at most %v", duration, tt.maxDuration)
+			}
+		})
+	}
+}
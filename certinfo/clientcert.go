@@ -0,0 +1,135 @@
+package certinfo
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//ClientCertificate client certificate and key pair for mutual TLS
+type ClientCertificate struct {
+	tls.Certificate
+	//Hosts - hosts, which certificate can be sent to, "*.example.com" matches every subdomain. If empty - certificate can be sent to any host
+	Hosts []string
+}
+
+//AllowedFor returns true if certificate can be sent to host
+func (c *ClientCertificate) AllowedFor(host string) bool {
+	if len(c.Hosts) == 0 {
+		return true
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, allowed := range c.Hosts {
+		if allowed == host || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+//acceptedBy returns true if certificate is issued by one of CAs accepted by server, or server does not restrict CAs
+//Issuer of every certificate of chain is checked, so certificate issued by intermediate of accepted CA is accepted too
+func (c *ClientCertificate) acceptedBy(acceptableCAs [][]byte) bool {
+	if len(acceptableCAs) == 0 {
+		return true
+	}
+	for i, der := range c.Certificate.Certificate {
+		cert := c.Leaf
+		if i > 0 || cert == nil {
+			var err error
+			if cert, err = x509.ParseCertificate(der); err != nil {
+				continue
+			}
+		}
+		for _, ca := range acceptableCAs {
+			if bytes.Equal(cert.RawIssuer, ca) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//ClientCertificates client certificate and key pairs for mutual TLS by name
+type ClientCertificates map[string]*ClientCertificate
+
+//clientCertificateJSON entry of client certificates config file
+type clientCertificateJSON struct {
+	CertFile string   `json:"cert_file"`
+	KeyFile  string   `json:"key_file"`
+	Hosts    []string `json:"hosts"`
+}
+
+//LoadClientCertificates reads client certificates config file and loads certificate and key pairs
+//Config file is JSON object with pair names as keys, for example:
+//{"internal": {"cert_file": "/etc/certcheckerbot/internal.crt", "key_file": "/etc/certcheckerbot/internal.key", "hosts": ["*.internal.example.com"]}}
+//Relative file paths are resolved from config file directory. Files must be in PEM format, certificate file can contain chain
+//hosts - optional list of hosts, which certificate can be sent to
+func LoadClientCertificates(path string) (ClientCertificates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("client certificate error - cannot read config file (%v)", err)
+	}
+	var config map[string]clientCertificateJSON
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("client certificate error - cannot parse config file (%v)", err)
+	}
+
+	result := ClientCertificates{}
+	for name, entry := range config {
+		if entry.CertFile == "" || entry.KeyFile == "" {
+			return nil, fmt.Errorf("client certificate error - cert_file and key_file must be set for %s", name)
+		}
+		certFile, keyFile := entry.CertFile, entry.KeyFile
+		if !filepath.IsAbs(certFile) {
+			certFile = filepath.Join(filepath.Dir(path), certFile)
+		}
+		if !filepath.IsAbs(keyFile) {
+			keyFile = filepath.Join(filepath.Dir(path), keyFile)
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate error - cannot load %s (%v)", name, err)
+		}
+		cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("client certificate error - cannot parse certificate of %s (%v)", name, err)
+		}
+		var hosts []string
+		for _, host := range entry.Hosts {
+			host = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+			if host == "" || host == "*." || (strings.Contains(host, "*") && !strings.HasPrefix(host, "*.")) {
+				return nil, fmt.Errorf("client certificate error - incorrect host %q of %s", host, name)
+			}
+			hosts = append(hosts, host)
+		}
+		result[name] = &ClientCertificate{Certificate: cert, Hosts: hosts}
+	}
+	return result, nil
+}
+
+//Names returns sorted names of client certificates
+func (c ClientCertificates) Names() []string {
+	var names []string
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//Get returns client certificate by name
+//Error does not list configured names, because it is shown to users
+func (c ClientCertificates) Get(name string) (*ClientCertificate, error) {
+	cert, ok := c[name]
+	if !ok {
+		return nil, fmt.Errorf("client certificate error - client certificate %s is not configured", name)
+	}
+	return cert, nil
+}
@@ -0,0 +1,218 @@
+package certinfo
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadClientCertificates(t *testing.T) {
+	client := newTestCert(t, testCertOptions{commonName: "monitoring client"}, nil)
+	other := newTestCert(t, testCertOptions{commonName: "other client"}, nil)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(client.key)
+	if err != nil {
+		t.Fatalf("cannot marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"client.crt": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: client.cert.Raw}),
+		"client.key": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		"other.crt":  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: other.cert.Raw}),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatalf("cannot write file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		config    string
+		wantNames []string
+		wantHosts []string
+		wantErr   bool
+	}{
+		{
+			name: "test relative and absolute paths",
+			config: `{"internal": {"cert_file": "client.crt", "key_file": "client.key"},
+				"absolute": {"cert_file": "` + filepath.Join(dir, "client.crt") + `", "key_file": "` + filepath.Join(dir, "client.key") + `"}}`,
+			wantNames: []string{"absolute", "internal"},
+		},
+		{
+			name:      "test allowed hosts",
+			config:    `{"internal": {"cert_file": "client.crt", "key_file": "client.key", "hosts": ["API.internal.example.com.", "*.corp.example.com"]}}`,
+			wantNames: []string{"internal"},
+			wantHosts: []string{"api.internal.example.com", "*.corp.example.com"},
+		},
+		{
+			name:    "test incorrect allowed host",
+			config:  `{"internal": {"cert_file": "client.crt", "key_file": "client.key", "hosts": ["api.*.example.com"]}}`,
+			wantErr: true,
+		},
+		{
+			name:   "test empty config",
+			config: `{}`,
+		},
+		{
+			name:    "test missing key file",
+			config:  `{"internal": {"cert_file": "client.crt"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "test not existing file",
+			config:  `{"internal": {"cert_file": "missing.crt", "key_file": "client.key"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "test key of other certificate",
+			config:  `{"internal": {"cert_file": "other.crt", "key_file": "client.key"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "test incorrect JSON",
+			config:  `["client.crt"]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "client_certs.json")
+			if err := os.WriteFile(path, []byte(tt.config), 0600); err != nil {
+				t.Fatalf("cannot write config: %v", err)
+			}
+			got, err := LoadClientCertificates(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadClientCertificates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			names := got.Names()
+			if len(names) != len(tt.wantNames) {
+				t.Fatalf("LoadClientCertificates() names = %v, want %v", names, tt.wantNames)
+			}
+			for i, name := range tt.wantNames {
+				if names[i] != name {
+					t.Errorf("LoadClientCertificates() names = %v, want %v", names, tt.wantNames)
+				}
+				cert, err := got.Get(name)
+				if err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				if !cert.Leaf.Equal(client.cert) {
+					t.Errorf("Get() certificate = %v, want %v", cert.Leaf.Subject, client.cert.Subject)
+				}
+				if !reflect.DeepEqual(cert.Hosts, tt.wantHosts) {
+					t.Errorf("Get() hosts = %v, want %v", cert.Hosts, tt.wantHosts)
+				}
+			}
+			if _, err := got.Get("missing"); err == nil {
+				t.Errorf("Get() of not configured certificate error = nil")
+			}
+		})
+	}
+}
+
+func TestClientCertificate_AllowedFor(t *testing.T) {
+	tests := []struct {
+		name  string
+		hosts []string
+		host  string
+		want  bool
+	}{
+		{name: "test not restricted certificate", hosts: nil, host: "example.com", want: true},
+		{name: "test allowed host", hosts: []string{"api.example.com"}, host: "API.example.com.", want: true},
+		{name: "test allowed subdomain", hosts: []string{"*.example.com"}, host: "a.b.example.com", want: true},
+		{name: "test wildcard does not match domain", hosts: []string{"*.example.com"}, host: "example.com", want: false},
+		{name: "test not allowed host", hosts: []string{"api.example.com"}, host: "attacker.example.org", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (&ClientCertificate{Hosts: tt.hosts}).AllowedFor(tt.host); got != tt.want {
+				t.Errorf("AllowedFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChecker_ClientCertificate(t *testing.T) {
+	_, _, root := newTestChain(t, "example.org")
+	leaf, intermediate, _ := newTestChain(t, "example.com")
+	client := newTestCert(t, testCertOptions{commonName: "monitoring client"}, nil)
+	startServer := func(clientCAs ...*x509.Certificate) string {
+		config := newTestTLSConfig(leaf, intermediate)
+		//with TLS 1.3 client handshake is completed before server checks client certificate
+		config.MaxVersion = tls.VersionTLS12
+		config.ClientAuth = tls.RequireAnyClientCert
+		if len(clientCAs) > 0 {
+			config.ClientCAs = x509.NewCertPool()
+			for _, ca := range clientCAs {
+				config.ClientCAs.AddCert(ca)
+			}
+		}
+		return startTestTLSServer(t, config)
+	}
+	address := startServer()
+	clientCert := tls.Certificate{Certificate: [][]byte{client.cert.Raw}, PrivateKey: client.key, Leaf: client.cert}
+
+	tests := []struct {
+		name    string
+		address string
+		cert    *ClientCertificate
+		wantErr bool
+	}{
+		{
+			name:    "test client certificate",
+			address: address,
+			cert:    &ClientCertificate{Certificate: clientCert},
+		},
+		{
+			name:    "test without client certificate",
+			address: address,
+			wantErr: true,
+		},
+		{
+			name:    "test client certificate allowed for host",
+			address: address,
+			cert:    &ClientCertificate{Certificate: clientCert, Hosts: []string{"*.com"}},
+		},
+		{
+			name:    "test client certificate is not allowed for host",
+			address: address,
+			cert:    &ClientCertificate{Certificate: clientCert, Hosts: []string{"internal.example.org"}},
+			wantErr: true,
+		},
+		{
+			name:    "test client certificate issued by accepted CA",
+			address: startServer(root.cert, client.cert),
+			cert:    &ClientCertificate{Certificate: clientCert},
+		},
+		{
+			name:    "test client certificate is not issued by accepted CA",
+			address: startServer(root.cert),
+			cert:    &ClientCertificate{Certificate: clientCert},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := (&Checker{}).WithClientCertificate(tt.cert)
+			report := checker.Check(context.Background(), "example.com@"+tt.address)
+			if (report.Err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", report.Err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := report.Leaf(); got == nil || !got.Certificate.Equal(leaf.cert) {
+				t.Errorf("Check() leaf certificate = %v, want %v", got, leaf.cert.Subject)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package certinfo
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+//CRL findings
+const (
+	FindingCRLError       FindingCode = "crl_error"
+	FindingCRLStale       FindingCode = "crl_stale"
+	FindingCRLUnreachable FindingCode = "crl_unreachable"
+)
+
+//maxCRLSize - max size of CRL downloaded from distribution point
+const maxCRLSize = 20 << 20
+
+//CRL statuses of certificate
+const (
+	CRLStatusGood    = "good"
+	CRLStatusRevoked = "revoked"
+)
+
+//CRLCache cache of downloaded CRLs
+type CRLCache interface {
+	//GetCRL returns cached raw CRL and its next update time. Returns error if CRL is not cached
+	GetCRL(URL string) ([]byte, time.Time, error)
+	//SaveCRL saves downloaded raw CRL with its next update time
+	SaveCRL(URL string, raw []byte, nextUpdate time.Time) error
+}
+
+//CRLReport result of CRL check of leaf certificate
+type CRLReport struct {
+	URL string `json:"url"`
+	//Cached - CRL is taken from cache
+	Cached     bool      `json:"cached"`
+	Status     string    `json:"status,omitempty"`
+	ThisUpdate time.Time `json:"this_update,omitempty"`
+	NextUpdate time.Time `json:"next_update,omitempty"`
+	RevokedAt  time.Time `json:"revoked_at,omitempty"`
+}
+
+//CheckCRL checks revocation status of leaf certificate with CRL distribution points of certificate
+//Distribution points are tried in order, until CRL, which is not stale, is loaded. Errors of other points are not reported then
+//CRL is taken from CRLCache if it is not stale, otherwise CRL is downloaded and saved to cache.
+//If CRL cannot be downloaded - stale cached CRL is used
+//Returns nil report if certificate has no CRL distribution points
+func (c *Checker) CheckCRL(ctx context.Context, leaf, issuer *x509.Certificate, now time.Time) (*CRLReport, []Finding) {
+	if len(leaf.CRLDistributionPoints) == 0 {
+		return nil, nil
+	}
+	report := &CRLReport{URL: leaf.CRLDistributionPoints[0]}
+	if issuer == nil {
+		return report, []Finding{{
+			Code:     FindingCRLError,
+			Severity: SeverityWarning,
+			Message:  "cannot check CRL - issuer certificate is not served",
+		}}
+	}
+
+	var findings []Finding
+	var crl *x509.RevocationList
+	var cached bool
+	for _, URL := range leaf.CRLDistributionPoints {
+		pointCRL, pointCached, pointFindings := c.loadCRL(ctx, URL, issuer, now)
+		findings = append(findings, pointFindings...)
+		if pointCRL == nil {
+			continue
+		}
+		if crl == nil || (crlStale(crl.NextUpdate, now) && !crlStale(pointCRL.NextUpdate, now)) {
+			crl, cached, report.URL = pointCRL, pointCached, URL
+		}
+		if !crlStale(crl.NextUpdate, now) {
+			findings = nil
+			break
+		}
+	}
+	if crl == nil {
+		return report, findings
+	}
+
+	report.Cached = cached
+	report.ThisUpdate = crl.ThisUpdate
+	report.NextUpdate = crl.NextUpdate
+	if crlStale(crl.NextUpdate, now) {
+		findings = append(findings, Finding{
+			Code:     FindingCRLStale,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("CRL %s is stale, next update was at %s", report.URL, report.NextUpdate.Format("2006-01-02 15:04:05")),
+		})
+	}
+
+	report.Status = CRLStatusGood
+	for _, revoked := range crl.RevokedCertificates {
+		if revoked.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+			report.Status = CRLStatusRevoked
+			report.RevokedAt = revoked.RevocationTime
+			findings = append(findings, Finding{
+				Code:     FindingRevoked,
+				Severity: SeverityCritical,
+				Message: fmt.Sprintf("leaf certificate %s is revoked at %s (CRL %s)",
+					leaf.Subject.CommonName, revoked.RevocationTime.Format("2006-01-02 15:04:05"), report.URL),
+			})
+			break
+		}
+	}
+	return report, findings
+}
+
+//crlStale returns true if next update time of CRL is passed, CRL without next update time is always stale
+func crlStale(nextUpdate time.Time, now time.Time) bool {
+	return !now.Before(nextUpdate)
+}
+
+//loadCRL returns CRL of distribution point from cache, CRL is downloaded if it is not cached or cached CRL is stale
+//Freshness of cached CRL is checked by stored next update time, so stale cached CRL is parsed only if CRL cannot be downloaded
+//If CRL cannot be downloaded - stale cached CRL is returned with findings of download error
+func (c *Checker) loadCRL(ctx context.Context, URL string, issuer *x509.Certificate, now time.Time) (*x509.RevocationList, bool, []Finding) {
+	var findings []Finding
+	raw, nextUpdate, ok := c.cachedCRL(URL)
+	if ok && !crlStale(nextUpdate, now) {
+		crl, err := parseCRL(raw, issuer)
+		if err == nil {
+			return crl, true, nil
+		}
+		log.Println(err)
+		ok = false
+	}
+
+	crl, err := c.downloadCRL(ctx, URL, issuer)
+	if err == nil {
+		return crl, false, nil
+	}
+	var parseErr *crlParseError
+	switch {
+	case errors.As(err, &parseErr):
+		findings = append(findings, Finding{
+			Code:     FindingCRLError,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("CRL %s is invalid - %v", URL, err),
+		})
+	default:
+		log.Println(err)
+		findings = append(findings, Finding{
+			Code:     FindingCRLUnreachable,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("cannot download CRL %s - %v", URL, err),
+		})
+	}
+	if !ok {
+		return nil, false, findings
+	}
+	stale, err := parseCRL(raw, issuer)
+	if err != nil {
+		log.Println(err)
+		return nil, false, findings
+	}
+	return stale, true, findings
+}
+
+//cachedCRL returns raw CRL and its next update time from cache, false if CRL is not cached
+func (c *Checker) cachedCRL(URL string) ([]byte, time.Time, bool) {
+	if c.CRLCache == nil {
+		return nil, time.Time{}, false
+	}
+	raw, nextUpdate, err := c.CRLCache.GetCRL(URL)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	return raw, nextUpdate, true
+}
+
+//downloadCRL downloads CRL, verifies its signature and saves it to cache
+//Returns *crlParseError if downloaded CRL is invalid
+func (c *Checker) downloadCRL(ctx context.Context, URL string, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.httpClient().Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returns %s", response.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(response.Body, maxCRLSize))
+	if err != nil {
+		return nil, err
+	}
+
+	crl, err := parseCRL(raw, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if c.CRLCache != nil {
+		if err := c.CRLCache.SaveCRL(URL, raw, crl.NextUpdate); err != nil {
+			log.Println(err)
+		}
+	}
+	return crl, nil
+}
+
+//crlParseError error of downloaded CRL parsing or verification
+type crlParseError struct {
+	err error
+}
+
+func (e *crlParseError) Error() string {
+	return e.err.Error()
+}
+
+//parseCRL parses PEM or DER encoded CRL and verifies its signature with issuer
+//Returns *crlParseError on error
+func parseCRL(raw []byte, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	if len(raw) == 0 {
+		return nil, &crlParseError{errors.New("crl error - empty CRL")}
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("-----BEGIN")) {
+		block, _ := pem.Decode(raw)
+		if block == nil || block.Type != "X509 CRL" {
+			return nil, &crlParseError{errors.New("crl error - cannot parse CRL (PEM block of X509 CRL is not found)")}
+		}
+		raw = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(raw)
+	if err != nil {
+		return nil, &crlParseError{fmt.Errorf("crl error - cannot parse CRL (%v)", err)}
+	}
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return nil, &crlParseError{fmt.Errorf("crl error - invalid CRL signature (%v)", err)}
+	}
+	return crl, nil
+}
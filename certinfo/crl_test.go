@@ -0,0 +1,331 @@
+package certinfo
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCRLCache in-memory CRL cache
+type testCRLCache struct {
+	crls        map[string][]byte
+	nextUpdates map[string]time.Time
+}
+
+func (c *testCRLCache) GetCRL(URL string) ([]byte, time.Time, error) {
+	raw, ok := c.crls[URL]
+	if !ok {
+		return nil, time.Time{}, errors.New("CRL is not cached")
+	}
+	return raw, c.nextUpdates[URL], nil
+}
+
+func (c *testCRLCache) SaveCRL(URL string, raw []byte, nextUpdate time.Time) error {
+	c.crls[URL] = raw
+	c.nextUpdates[URL] = nextUpdate
+	return nil
+}
+
+// testCRLServer local CRL distribution point
+type testCRLServer struct {
+	mu       sync.Mutex
+	crl      []byte
+	requests int
+}
+
+func (s *testCRLServer) set(crl []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.crl = crl
+}
+
+func (s *testCRLServer) getRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func startTestCRLServer(t *testing.T, crlServer *testCRLServer) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		crlServer.mu.Lock()
+		crlServer.requests++
+		crl := crlServer.crl
+		crlServer.mu.Unlock()
+		if crl == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(crl)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestCRL creates CRL signed by issuer
+func newTestCRL(t *testing.T, issuer *testCert, nextUpdate time.Time, revoked ...*big.Int) []byte {
+	var revokedCerts []pkix.RevokedCertificate
+	for _, serial := range revoked {
+		revokedCerts = append(revokedCerts, pkix.RevokedCertificate{SerialNumber: serial, RevocationTime: time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)})
+	}
+	template := &x509.RevocationList{
+		RevokedCertificates: revokedCerts,
+		Number:              big.NewInt(1),
+		ThisUpdate:          time.Now().Add(-2 * time.Hour),
+		NextUpdate:          nextUpdate,
+	}
+	raw, err := x509.CreateRevocationList(rand.Reader, template, issuer.cert, issuer.key)
+	if err != nil {
+		t.Fatalf("cannot create CRL: %v", err)
+	}
+	return raw
+}
+
+func TestChecker_CheckCRL(t *testing.T) {
+	_, intermediate, root := newTestChain(t, "crl.example.com")
+	crlServer := &testCRLServer{}
+	server := startTestCRLServer(t, crlServer)
+	leaf := newTestCert(t, testCertOptions{
+		commonName: "crl.example.com",
+		dnsNames:   []string{"crl.example.com"},
+		crlServers: []string{server.URL + "/intermediate.crl"},
+	}, intermediate)
+	noCRLLeaf := newTestCert(t, testCertOptions{commonName: "crl.example.com", dnsNames: []string{"crl.example.com"}}, intermediate)
+	fresh := time.Now().Add(24 * time.Hour)
+	stale := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name             string
+		leaf             *testCert
+		issuer           *testCert
+		cached           []byte
+		cachedNextUpdate time.Time
+		served           []byte
+		wantReport       bool
+		wantStatus       string
+		wantCached       bool
+		wantRequests     int
+		wantCachedAfter  bool
+		wantFindingCodes []FindingCode
+	}{
+		{
+			name:            "test good certificate",
+			leaf:            leaf,
+			issuer:          intermediate,
+			served:          newTestCRL(t, intermediate, fresh, big.NewInt(1)),
+			wantReport:      true,
+			wantStatus:      CRLStatusGood,
+			wantRequests:    1,
+			wantCachedAfter: true,
+		},
+		{
+			name:             "test revoked certificate",
+			leaf:             leaf,
+			issuer:           intermediate,
+			served:           newTestCRL(t, intermediate, fresh, big.NewInt(1), leaf.cert.SerialNumber),
+			wantReport:       true,
+			wantStatus:       CRLStatusRevoked,
+			wantRequests:     1,
+			wantCachedAfter:  true,
+			wantFindingCodes: []FindingCode{FindingRevoked},
+		},
+		{
+			name:             "test PEM encoded revoked certificate",
+			leaf:             leaf,
+			issuer:           intermediate,
+			served:           pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: newTestCRL(t, intermediate, fresh, leaf.cert.SerialNumber)}),
+			wantReport:       true,
+			wantStatus:       CRLStatusRevoked,
+			wantRequests:     1,
+			wantCachedAfter:  true,
+			wantFindingCodes: []FindingCode{FindingRevoked},
+		},
+		{
+			name:             "test cached CRL",
+			leaf:             leaf,
+			issuer:           intermediate,
+			cached:           newTestCRL(t, intermediate, fresh, leaf.cert.SerialNumber),
+			cachedNextUpdate: fresh,
+			wantReport:       true,
+			wantStatus:       CRLStatusRevoked,
+			wantCached:       true,
+			wantRequests:     0,
+			wantCachedAfter:  true,
+			wantFindingCodes: []FindingCode{FindingRevoked},
+		},
+		{
+			name:             "test stale cached CRL is updated",
+			leaf:             leaf,
+			issuer:           intermediate,
+			cached:           newTestCRL(t, intermediate, stale, leaf.cert.SerialNumber),
+			cachedNextUpdate: stale,
+			served:           newTestCRL(t, intermediate, fresh),
+			wantReport:       true,
+			wantStatus:       CRLStatusGood,
+			wantRequests:     1,
+			wantCachedAfter:  true,
+		},
+		{
+			name:             "test stale cached CRL and unreachable distribution point",
+			leaf:             leaf,
+			issuer:           intermediate,
+			cached:           newTestCRL(t, intermediate, stale),
+			cachedNextUpdate: stale,
+			wantReport:       true,
+			wantStatus:       CRLStatusGood,
+			wantCached:       true,
+			wantRequests:     1,
+			wantCachedAfter:  true,
+			wantFindingCodes: []FindingCode{FindingCRLUnreachable, FindingCRLStale},
+		},
+		{
+			name:             "test stale CRL",
+			leaf:             leaf,
+			issuer:           intermediate,
+			served:           newTestCRL(t, intermediate, stale),
+			wantReport:       true,
+			wantStatus:       CRLStatusGood,
+			wantRequests:     1,
+			wantCachedAfter:  true,
+			wantFindingCodes: []FindingCode{FindingCRLStale},
+		},
+		{
+			name:             "test unreachable distribution point",
+			leaf:             leaf,
+			issuer:           intermediate,
+			wantReport:       true,
+			wantRequests:     1,
+			wantFindingCodes: []FindingCode{FindingCRLUnreachable},
+		},
+		{
+			name:             "test CRL signed by other CA",
+			leaf:             leaf,
+			issuer:           intermediate,
+			served:           newTestCRL(t, root, fresh),
+			wantReport:       true,
+			wantRequests:     1,
+			wantFindingCodes: []FindingCode{FindingCRLError},
+		},
+		{
+			name:             "test issuer is not served",
+			leaf:             leaf,
+			wantReport:       true,
+			wantFindingCodes: []FindingCode{FindingCRLError},
+		},
+		{
+			name:   "test no CRL distribution points",
+			leaf:   noCRLLeaf,
+			issuer: intermediate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crlServer.set(tt.served)
+			requests := crlServer.getRequests()
+			cache := &testCRLCache{crls: map[string][]byte{}, nextUpdates: map[string]time.Time{}}
+			if tt.cached != nil {
+				cache.crls[server.URL+"/intermediate.crl"] = tt.cached
+				cache.nextUpdates[server.URL+"/intermediate.crl"] = tt.cachedNextUpdate
+			}
+			var issuer *x509.Certificate
+			if tt.issuer != nil {
+				issuer = tt.issuer.cert
+			}
+
+			checker := &Checker{HTTPClient: server.Client(), CRLCache: cache}
+			report, findings := checker.CheckCRL(context.Background(), tt.leaf.cert, issuer, time.Now())
+			if (report != nil) != tt.wantReport {
+				t.Fatalf("CheckCRL() report = %v, want report %v", report, tt.wantReport)
+			}
+			if report != nil && (report.Status != tt.wantStatus || report.Cached != tt.wantCached) {
+				t.Errorf("CheckCRL() status = %v, cached = %v, want %v, %v", report.Status, report.Cached, tt.wantStatus, tt.wantCached)
+			}
+			if got := crlServer.getRequests() - requests; got != tt.wantRequests {
+				t.Errorf("CheckCRL() distribution point requests = %v, want %v", got, tt.wantRequests)
+			}
+			if _, ok := cache.crls[server.URL+"/intermediate.crl"]; ok != tt.wantCachedAfter {
+				t.Errorf("CheckCRL() CRL is cached = %v, want %v", ok, tt.wantCachedAfter)
+			}
+			if got := findingsCodes(findings); !reflect.DeepEqual(got, tt.wantFindingCodes) {
+				t.Errorf("CheckCRL() findings = %v, want %v", got, tt.wantFindingCodes)
+			}
+		})
+	}
+}
+
+func TestChecker_CheckCRL_distributionPoints(t *testing.T) {
+	_, intermediate, _ := newTestChain(t, "crl.example.com")
+	firstServer := &testCRLServer{}
+	secondServer := &testCRLServer{}
+	first := startTestCRLServer(t, firstServer)
+	second := startTestCRLServer(t, secondServer)
+	leaf := newTestCert(t, testCertOptions{
+		commonName: "crl.example.com",
+		dnsNames:   []string{"crl.example.com"},
+		crlServers: []string{first.URL + "/intermediate.crl", second.URL + "/intermediate.crl"},
+	}, intermediate)
+	fresh := time.Now().Add(24 * time.Hour)
+	stale := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name             string
+		firstServed      []byte
+		secondServed     []byte
+		wantURL          string
+		wantStatus       string
+		wantFindingCodes []FindingCode
+	}{
+		{
+			name:         "test first distribution point",
+			firstServed:  newTestCRL(t, intermediate, fresh),
+			secondServed: newTestCRL(t, intermediate, fresh, leaf.cert.SerialNumber),
+			wantURL:      first.URL + "/intermediate.crl",
+			wantStatus:   CRLStatusGood,
+		},
+		{
+			name:             "test unreachable first distribution point",
+			secondServed:     newTestCRL(t, intermediate, fresh, leaf.cert.SerialNumber),
+			wantURL:          second.URL + "/intermediate.crl",
+			wantStatus:       CRLStatusRevoked,
+			wantFindingCodes: []FindingCode{FindingRevoked},
+		},
+		{
+			name:         "test stale first distribution point",
+			firstServed:  newTestCRL(t, intermediate, stale),
+			secondServed: newTestCRL(t, intermediate, fresh),
+			wantURL:      second.URL + "/intermediate.crl",
+			wantStatus:   CRLStatusGood,
+		},
+		{
+			name:             "test unreachable distribution points",
+			wantURL:          first.URL + "/intermediate.crl",
+			wantFindingCodes: []FindingCode{FindingCRLUnreachable, FindingCRLUnreachable},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			firstServer.set(tt.firstServed)
+			secondServer.set(tt.secondServed)
+
+			report, findings := (&Checker{}).CheckCRL(context.Background(), leaf.cert, intermediate.cert, time.Now())
+			if report == nil {
+				t.Fatalf("CheckCRL() report = nil")
+			}
+			if report.URL != tt.wantURL || report.Status != tt.wantStatus {
+				t.Errorf("CheckCRL() URL = %v, status = %v, want %v, %v", report.URL, report.Status, tt.wantURL, tt.wantStatus)
+			}
+			if got := findingsCodes(findings); !reflect.DeepEqual(got, tt.wantFindingCodes) {
+				t.Errorf("CheckCRL() findings = %v, want %v", got, tt.wantFindingCodes)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package certinfo
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/net/dns/dnsmessage"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+//maxDNSMessageSize - max size of DNS response over TCP
+const maxDNSMessageSize = 65535
+
+//DNSResolver resolves CAA and TLSA records with DNS queries to recursive nameserver
+type DNSResolver struct {
+	//Nameserver - address of recursive DNS server in host:port format
+	Nameserver string
+	//Timeout - timeout of single query. If 0 - DefaultConnectTimeout is used
+	Timeout time.Duration
+}
+
+//NewDNSResolver creates resolver with nameserver address
+//If nameserver is empty - first nameserver from /etc/resolv.conf is used. If port is not specified - 53 is used
+func NewDNSResolver(nameserver string) (*DNSResolver, error) {
+	if nameserver == "" {
+		var err error
+		nameserver, err = systemNameserver("/etc/resolv.conf")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, _, err := net.SplitHostPort(nameserver); err != nil {
+		nameserver = net.JoinHostPort(strings.Trim(nameserver, "[]"), "53")
+	}
+	return &DNSResolver{Nameserver: nameserver}, nil
+}
+
+//systemNameserver returns first nameserver from resolv.conf file
+func systemNameserver(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("dns resolver error - cannot read %s (%v)", path, err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return fields[1], nil
+		}
+	}
+	return "", fmt.Errorf("dns resolver error - there is no nameserver in %s", path)
+}
+
+//lookup queries records of type over UDP, truncated responses are repeated over TCP
+//Returns data of answer records and authenticated flag, non-existent domain has no records
+//authenticated - AD bit of response, answer is validated with DNSSEC by nameserver (RFC 4035, 3.2.3)
+//AD bit is requested in query without DNSSEC records (RFC 6840, 5.7), nameserver must be trusted validating resolver
+func (r *DNSResolver) lookup(ctx context.Context, domain string, recordType dnsmessage.Type) ([][]byte, bool, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(domain, ".") + ".")
+	if err != nil {
+		return nil, false, fmt.Errorf("dns resolver error - invalid domain name %s (%v)", domain, err)
+	}
+	idBytes := make([]byte, 2)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, false, err
+	}
+	id := binary.BigEndian.Uint16(idBytes)
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true, AuthenticData: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: recordType, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, false, err
+	}
+
+	response, err := r.exchange(ctx, "udp", packed)
+	if err != nil {
+		return nil, false, err
+	}
+	answers, header, err := parseDNSResponse(response, id, recordType)
+	if err == nil && header.Truncated {
+		response, err = r.exchange(ctx, "tcp", packed)
+		if err != nil {
+			return nil, false, err
+		}
+		answers, header, err = parseDNSResponse(response, id, recordType)
+	}
+	return answers, err == nil && header.AuthenticData, err
+}
+
+//exchange sends packed DNS query to nameserver and returns packed response
+func (r *DNSResolver) exchange(ctx context.Context, network string, query []byte) ([]byte, error) {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = DefaultConnectTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, r.Nameserver)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		response := make([]byte, maxDNSMessageSize)
+		n, err := conn.Read(response)
+		if err != nil {
+			return nil, err
+		}
+		return response[:n], nil
+	}
+
+	//DNS messages over TCP are prefixed with 2 bytes length (RFC 1035, 4.2.2)
+	prefixed := make([]byte, 2, 2+len(query))
+	binary.BigEndian.PutUint16(prefixed, uint16(len(query)))
+	if _, err := conn.Write(append(prefixed, query...)); err != nil {
+		return nil, err
+	}
+	length := make([]byte, 2)
+	if _, err := io.ReadFull(conn, length); err != nil {
+		return nil, err
+	}
+	response := make([]byte, binary.BigEndian.Uint16(length))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+//parseDNSResponse returns data of answer records of type and header of response, answers of truncated response are not parsed
+//Non-existent domain is not an error, it has no records
+func parseDNSResponse(response []byte, id uint16, recordType dnsmessage.Type) ([][]byte, dnsmessage.Header, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	if err != nil {
+		return nil, dnsmessage.Header{}, fmt.Errorf("dns resolver error - cannot parse DNS response (%v)", err)
+	}
+	if header.ID != id || !header.Response {
+		return nil, dnsmessage.Header{}, errors.New("dns resolver error - unexpected DNS response")
+	}
+	if header.Truncated {
+		return nil, header, nil
+	}
+	switch header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, header, nil
+	default:
+		return nil, header, fmt.Errorf("dns resolver error - DNS server returns %s", header.RCode)
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return nil, header, fmt.Errorf("dns resolver error - cannot parse DNS response (%v)", err)
+	}
+
+	var answers [][]byte
+	for {
+		answer, err := parser.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, header, fmt.Errorf("dns resolver error - cannot parse DNS response (%v)", err)
+		}
+		//CNAME records of alias chain are skipped
+		if answer.Type != recordType {
+			if err := parser.SkipAnswer(); err != nil {
+				return nil, header, fmt.Errorf("dns resolver error - cannot parse DNS response (%v)", err)
+			}
+			continue
+		}
+		resource, err := parser.UnknownResource()
+		if err != nil {
+			return nil, header, fmt.Errorf("dns resolver error - cannot parse DNS response (%v)", err)
+		}
+		answers = append(answers, resource.Data)
+	}
+	return answers, header, nil
+}
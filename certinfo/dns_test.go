@@ -0,0 +1,137 @@
+package certinfo
+
+import (
+	"encoding/binary"
+	"golang.org/x/net/dns/dnsmessage"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//testDNSServer local DNS server, which answers queries over UDP and TCP
+type testDNSServer struct {
+	//records - records of domains, only records of queried type are answered
+	records map[string][]dnsmessage.UnknownResource
+	rcodes  map[string]dnsmessage.RCode
+	//truncate - UDP responses of these domains are truncated
+	truncate map[string]bool
+	//authenticated - responses of these domains have AD bit, as if they are validated with DNSSEC
+	authenticated map[string]bool
+}
+
+//response returns packed response to packed query
+func (s *testDNSServer) response(query []byte, udp bool) []byte {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil
+	}
+	question, err := parser.Question()
+	if err != nil {
+		return nil
+	}
+	domain := question.Name.String()
+	domain = domain[:len(domain)-1]
+
+	header.Response = true
+	header.RCode = s.rcodes[domain]
+	header.AuthenticData = s.authenticated[domain]
+	builder := dnsmessage.NewBuilder(nil, header)
+	_ = builder.StartQuestions()
+	_ = builder.Question(question)
+	if udp && s.truncate[domain] {
+		header.Truncated = true
+		builder = dnsmessage.NewBuilder(nil, header)
+		_ = builder.StartQuestions()
+		_ = builder.Question(question)
+	} else {
+		_ = builder.StartAnswers()
+		for _, record := range s.records[domain] {
+			if record.Type != question.Type {
+				continue
+			}
+			_ = builder.UnknownResource(dnsmessage.ResourceHeader{Name: question.Name, Type: record.Type, Class: dnsmessage.ClassINET, TTL: 60}, record)
+		}
+	}
+	response, _ := builder.Finish()
+	return response
+}
+
+//start starts UDP and TCP listeners on the same port, returns address
+func (s *testDNSServer) start(t *testing.T) string {
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen UDP: %v", err)
+	}
+	t.Cleanup(func() { _ = packetConn.Close() })
+	listener, err := net.Listen("tcp", packetConn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("cannot listen TCP: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		buffer := make([]byte, 512)
+		for {
+			n, addr, err := packetConn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			_, _ = packetConn.WriteTo(s.response(buffer[:n], true), addr)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			length := make([]byte, 2)
+			if _, err := io.ReadFull(conn, length); err == nil {
+				query := make([]byte, binary.BigEndian.Uint16(length))
+				if _, err := io.ReadFull(conn, query); err == nil {
+					response := s.response(query, false)
+					prefixed := make([]byte, 2, 2+len(response))
+					binary.BigEndian.PutUint16(prefixed, uint16(len(response)))
+					_, _ = conn.Write(append(prefixed, response...))
+				}
+			}
+			_ = conn.Close()
+		}
+	}()
+	return packetConn.LocalAddr().String()
+}
+
+func TestNewDNSResolver(t *testing.T) {
+	resolvConf := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(resolvConf, []byte("# comment\nsearch example.com\nnameserver 10.0.0.1\nnameserver 10.0.0.2\n"), 0600); err != nil {
+		t.Fatalf("cannot write resolv.conf: %v", err)
+	}
+	nameserver, err := systemNameserver(resolvConf)
+	if err != nil || nameserver != "10.0.0.1" {
+		t.Errorf("systemNameserver() = %v, %v, want 10.0.0.1", nameserver, err)
+	}
+	if _, err := systemNameserver(filepath.Join(t.TempDir(), "missing.conf")); err == nil {
+		t.Errorf("systemNameserver() error = nil for missing file")
+	}
+
+	tests := []struct {
+		nameserver string
+		want       string
+	}{
+		{nameserver: "10.0.0.1", want: "10.0.0.1:53"},
+		{nameserver: "10.0.0.1:5353", want: "10.0.0.1:5353"},
+		{nameserver: "::1", want: "[::1]:53"},
+		{nameserver: "[::1]:5353", want: "[::1]:5353"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nameserver, func(t *testing.T) {
+			resolver, err := NewDNSResolver(tt.nameserver)
+			if err != nil || resolver.Nameserver != tt.want {
+				t.Errorf("NewDNSResolver() = %v, %v, want %v", resolver, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package certinfo
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"software.sslmate.com/src/go-pkcs12"
+	"strings"
+	"time"
+)
+
+//MaxCertFileSize - max size of inspected certificate file
+const MaxCertFileSize = 1 << 20
+
+//FindingInvalidRequestSignature - signature of certificate signing request does not match its public key
+const FindingInvalidRequestSignature FindingCode = "invalid_request_signature"
+
+//errEmptyCertFile - file does not contain certificates or certificate signing requests
+var errEmptyCertFile = errors.New("file error - file does not contain certificates or certificate signing requests")
+
+//errNoCertificates - file does not contain certificates, for example it contains certificate signing requests only
+var errNoCertificates = errors.New("file error - file does not contain certificates")
+
+//CertFile certificates and certificate signing requests parsed from file
+type CertFile struct {
+	//Certificates - certificates in chain order, leaf certificate first
+	Certificates []*x509.Certificate
+	Requests     []*x509.CertificateRequest
+	//HasPrivateKey - file contains private key. Private key itself is skipped and is not returned
+	HasPrivateKey bool
+}
+
+//RequestReport result of certificate signing request inspection
+type RequestReport struct {
+	Subject            string    `json:"subject"`
+	CommonName         string    `json:"common_name"`
+	DNSNames           []string  `json:"dns_names,omitempty"`
+	IPAddresses        []string  `json:"ip_addresses,omitempty"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	PublicKeyAlgorithm string    `json:"public_key_algorithm"`
+	KeySize            int       `json:"key_size,omitempty"`
+	KeyCurve           string    `json:"key_curve,omitempty"`
+	Findings           []Finding `json:"findings,omitempty"`
+}
+
+//ParseCertFile parses certificates and certificate signing requests from file or pasted text
+//Supported formats: PEM (few blocks are allowed, text around blocks is skipped), DER, PKCS#7 and PKCS#12
+//password - password of PKCS#12 file, is not used for other formats
+//Private keys are skipped, only HasPrivateKey flag is set
+func ParseCertFile(data []byte, password string) (*CertFile, error) {
+	if len(data) > MaxCertFileSize {
+		return nil, fmt.Errorf("file error - file is larger than %d KB", MaxCertFileSize/1024)
+	}
+
+	var file *CertFile
+	var err error
+	if bytes.Contains(data, []byte("-----BEGIN ")) {
+		file, err = parsePEMFile(data)
+	} else {
+		file, err = parseDERFile(data, password)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Certificates) == 0 && len(file.Requests) == 0 {
+		return nil, errEmptyCertFile
+	}
+	file.Certificates = orderChain(file.Certificates)
+	return file, nil
+}
+
+//parsePEMFile parses PEM blocks of certificates, certificate signing requests and PKCS#7 certificates
+func parsePEMFile(data []byte) (*CertFile, error) {
+	file := &CertFile{}
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch {
+		case block.Type == "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("file error - cannot parse certificate (%v)", err)
+			}
+			file.Certificates = append(file.Certificates, cert)
+		case block.Type == "CERTIFICATE REQUEST" || block.Type == "NEW CERTIFICATE REQUEST":
+			csr, err := x509.ParseCertificateRequest(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("file error - cannot parse certificate signing request (%v)", err)
+			}
+			file.Requests = append(file.Requests, csr)
+		case block.Type == "PKCS7":
+			certs, err := parsePKCS7Certificates(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("file error - cannot parse PKCS#7 certificates (%v)", err)
+			}
+			file.Certificates = append(file.Certificates, certs...)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			file.HasPrivateKey = true
+		}
+	}
+	return file, nil
+}
+
+//parseDERFile parses DER encoded certificate, certificate signing request, PKCS#7 certificates or PKCS#12 file
+func parseDERFile(data []byte, password string) (*CertFile, error) {
+	if cert, err := x509.ParseCertificate(data); err == nil {
+		return &CertFile{Certificates: []*x509.Certificate{cert}}, nil
+	}
+	if csr, err := x509.ParseCertificateRequest(data); err == nil {
+		return &CertFile{Requests: []*x509.CertificateRequest{csr}}, nil
+	}
+	if certs, err := parsePKCS7Certificates(data); err == nil {
+		return &CertFile{Certificates: certs}, nil
+	}
+
+	blocks, err := pkcs12.ToPEM(data, password)
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		return nil, errors.New("file error - incorrect password of PKCS#12 file")
+	}
+	if err != nil {
+		return nil, errors.New("file error - unsupported file format, PEM, DER, PKCS#7 and PKCS#12 files are supported")
+	}
+	file := &CertFile{}
+	for _, block := range blocks {
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("file error - cannot parse certificate of PKCS#12 file (%v)", err)
+			}
+			file.Certificates = append(file.Certificates, cert)
+		case "PRIVATE KEY":
+			file.HasPrivateKey = true
+		}
+	}
+	return file, nil
+}
+
+//orderChain sorts certificates in chain order: leaf certificate first, then its issuers
+//Leaf certificate is the first certificate, which does not issue other certificates
+//Certificates, which are not in chain of leaf certificate, are added to the end in file order
+func orderChain(certs []*x509.Certificate) []*x509.Certificate {
+	if len(certs) < 2 {
+		return certs
+	}
+	isIssuer := func(cert *x509.Certificate) bool {
+		for _, other := range certs {
+			if other != cert && bytes.Equal(other.RawIssuer, cert.RawSubject) && !bytes.Equal(other.RawSubject, cert.RawSubject) {
+				return true
+			}
+		}
+		return false
+	}
+
+	used := make([]bool, len(certs))
+	var chain []*x509.Certificate
+	for i, cert := range certs {
+		if !isIssuer(cert) {
+			chain = append(chain, cert)
+			used[i] = true
+			break
+		}
+	}
+	for len(chain) > 0 && len(chain) < len(certs) {
+		last := chain[len(chain)-1]
+		next := -1
+		for i, cert := range certs {
+			if !used[i] && bytes.Equal(cert.RawSubject, last.RawIssuer) {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			break
+		}
+		chain = append(chain, certs[next])
+		used[next] = true
+	}
+	for i, cert := range certs {
+		if !used[i] {
+			chain = append(chain, cert)
+		}
+	}
+	return chain
+}
+
+//InspectCertificates checks certificates from file like served chain, but hostname is not verified
+//name - file name, used as report target
+//certs - certificates in chain order, first certificate must be a leaf
+//Errors are returned in report
+func (c *Checker) InspectCertificates(ctx context.Context, name string, certs []*x509.Certificate) *CertReport {
+	report := &CertReport{
+		Target:    name,
+		StartedAt: time.Now(),
+	}
+	defer func() {
+		report.Duration = time.Since(report.StartedAt)
+	}()
+	if len(certs) == 0 {
+		report.setError(ErrorCategoryFile, errNoCertificates)
+		return report
+	}
+
+	for i, cert := range certs {
+		report.Chain = append(report.Chain, newCertDetails(i, cert))
+	}
+	report.Findings = VerifyChain(certs, "", c.Roots, time.Now())
+	report.Findings = append(report.Findings, CheckKeyPolicy(certs, c.KeyPolicy)...)
+	c.checkLeafStatus(ctx, report, certs, nil, nil, nil)
+	return report
+}
+
+//InspectRequest checks signature and key of certificate signing request
+func (c *Checker) InspectRequest(csr *x509.CertificateRequest) *RequestReport {
+	var ips []string
+	for _, ip := range csr.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+	algorithm, keySize, keyCurve := keyInfo(csr.PublicKey, csr.PublicKeyAlgorithm)
+	report := &RequestReport{
+		Subject:            csr.Subject.String(),
+		CommonName:         csr.Subject.CommonName,
+		DNSNames:           csr.DNSNames,
+		IPAddresses:        ips,
+		SignatureAlgorithm: csr.SignatureAlgorithm.String(),
+		PublicKeyAlgorithm: algorithm,
+		KeySize:            keySize,
+		KeyCurve:           keyCurve,
+	}
+
+	name := "certificate signing request " + csr.Subject.CommonName
+	if err := csr.CheckSignature(); err != nil {
+		report.Findings = append(report.Findings, Finding{
+			Code:     FindingInvalidRequestSignature,
+			Severity: SeverityCritical,
+			Message:  fmt.Sprintf("%s has invalid signature (%v)", name, err),
+		})
+	}
+	report.Findings = append(report.Findings, keyFindings(name, csr.PublicKey, csr.PublicKeyAlgorithm, c.KeyPolicy)...)
+	if isWeakSignature(csr.SignatureAlgorithm) {
+		report.Findings = append(report.Findings, Finding{
+			Code:     FindingWeakSignature,
+			Severity: SeverityCritical,
+			Message:  fmt.Sprintf("%s is signed with weak algorithm %s", name, csr.SignatureAlgorithm),
+		})
+	}
+	return report
+}
+
+//CleanFilePattern checks that pattern is absolute path or glob pattern of local files and returns cleaned pattern
+func CleanFilePattern(pattern string) (string, error) {
+	pattern = strings.TrimSpace(pattern)
+	if !filepath.IsAbs(pattern) {
+		return "", fmt.Errorf("file error - path %s is not absolute", pattern)
+	}
+	pattern = filepath.Clean(pattern)
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return "", fmt.Errorf("file error - incorrect glob pattern %s (%v)", pattern, err)
+	}
+	return pattern, nil
+}
+
+//CheckFile inspects certificates of local file like InspectCertificates, PKCS#12 files are read with empty password
+//Errors are returned in report
+func (c *Checker) CheckFile(ctx context.Context, path string) *CertReport {
+	data, err := readCertFile(path)
+	if err == nil {
+		var file *CertFile
+		file, err = ParseCertFile(data, "")
+		if err == nil {
+			return c.InspectCertificates(ctx, path, file.Certificates)
+		}
+	}
+	report := &CertReport{Target: path, StartedAt: time.Now()}
+	report.setError(ErrorCategoryFile, err)
+	return report
+}
+
+//CheckFiles inspects certificates of every local file matched by glob pattern, files are checked in lexical order
+//Matched files without certificates (for example private keys) are skipped
+//Returns error if pattern does not match any file with certificates
+func (c *Checker) CheckFiles(ctx context.Context, pattern string) ([]*CertReport, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("file error - incorrect glob pattern %s (%v)", pattern, err)
+	}
+	var reports []*CertReport
+	//lastErr - error of skipped file, returned if pattern matches single file
+	var lastErr error
+	for _, path := range paths {
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		report := c.CheckFile(ctx, path)
+		if errors.Is(report.Err, errEmptyCertFile) || errors.Is(report.Err, errNoCertificates) {
+			lastErr = report.Err
+			continue
+		}
+		reports = append(reports, report)
+	}
+	if len(reports) == 0 {
+		if len(paths) == 1 && lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, fmt.Errorf("file error - %s does not match any certificate file", pattern)
+	}
+	return reports, nil
+}
+
+//readCertFile reads local file up to MaxCertFileSize+1 bytes, larger files are rejected by ParseCertFile
+func readCertFile(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("file error - cannot open %s (%v)", path, errors.Unwrap(err))
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, MaxCertFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("file error - cannot read %s (%v)", path, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,409 @@
+package certinfo
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"software.sslmate.com/src/go-pkcs12"
+	"strings"
+	"testing"
+	"time"
+)
+
+//newTestRequest creates certificate signing request for dnsNames signed by key
+func newTestRequest(t *testing.T, key interface{}, dnsNames ...string) *x509.CertificateRequest {
+	template := &x509.CertificateRequest{Subject: pkix.Name{CommonName: dnsNames[0]}, DNSNames: dnsNames}
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		t.Fatalf("cannot create certificate signing request: %v", err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatalf("cannot parse certificate signing request: %v", err)
+	}
+	return csr
+}
+
+func TestParseCertFile(t *testing.T) {
+	leaf, intermediate, root := newTestChain(t, "example.com")
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	csr := newTestRequest(t, key, "example.com")
+	keyDER, err := x509.MarshalPKCS8PrivateKey(leaf.key)
+	if err != nil {
+		t.Fatalf("cannot marshal key: %v", err)
+	}
+	p12, err := pkcs12.Encode(rand.Reader, leaf.key, leaf.cert, []*x509.Certificate{root.cert, intermediate.cert}, "secret")
+	if err != nil {
+		t.Fatalf("cannot create PKCS#12 file: %v", err)
+	}
+
+	certPEM := func(certs ...*testCert) string {
+		result := ""
+		for _, cert := range certs {
+			result += string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.cert.Raw}))
+		}
+		return result
+	}
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+	csrPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr.Raw}))
+
+	tests := []struct {
+		name          string
+		data          []byte
+		password      string
+		wantCerts     []*x509.Certificate
+		wantRequests  int
+		wantKey       bool
+		wantErrPrefix string
+	}{
+		{
+			name:      "test PEM chain",
+			data:      []byte(certPEM(leaf, intermediate)),
+			wantCerts: []*x509.Certificate{leaf.cert, intermediate.cert},
+		},
+		{
+			name:      "test PEM chain in reverse order",
+			data:      []byte(certPEM(root, intermediate, leaf)),
+			wantCerts: []*x509.Certificate{leaf.cert, intermediate.cert, root.cert},
+		},
+		{
+			name:      "test pasted PEM with text and private key",
+			data:      []byte("Here is our new cert:\n" + keyPEM + certPEM(leaf) + "\nthanks"),
+			wantCerts: []*x509.Certificate{leaf.cert},
+			wantKey:   true,
+		},
+		{
+			name:         "test PEM certificate signing request",
+			data:         []byte(csrPEM),
+			wantRequests: 1,
+		},
+		{
+			name:      "test DER certificate",
+			data:      leaf.cert.Raw,
+			wantCerts: []*x509.Certificate{leaf.cert},
+		},
+		{
+			name:         "test DER certificate signing request",
+			data:         csr.Raw,
+			wantRequests: 1,
+		},
+		{
+			name:      "test PKCS#7 certificates",
+			data:      newTestPKCS7(t, intermediate.cert, leaf.cert),
+			wantCerts: []*x509.Certificate{leaf.cert, intermediate.cert},
+		},
+		{
+			name:      "test PKCS#12 file",
+			data:      p12,
+			password:  "secret",
+			wantCerts: []*x509.Certificate{leaf.cert, intermediate.cert, root.cert},
+			wantKey:   true,
+		},
+		{
+			name:          "test PKCS#12 file with incorrect password",
+			data:          p12,
+			password:      "wrong",
+			wantErrPrefix: "file error - incorrect password",
+		},
+		{
+			name:          "test private key only",
+			data:          []byte(keyPEM),
+			wantErrPrefix: "file error - file does not contain",
+		},
+		{
+			name:          "test unsupported file",
+			data:          []byte("not a certificate"),
+			wantErrPrefix: "file error - unsupported file format",
+		},
+		{
+			name:          "test too large file",
+			data:          make([]byte, MaxCertFileSize+1),
+			wantErrPrefix: "file error - file is larger",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCertFile(tt.data, tt.password)
+			if tt.wantErrPrefix != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErrPrefix) {
+					t.Fatalf("ParseCertFile() error = %v, want prefix %v", err, tt.wantErrPrefix)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCertFile() error = %v", err)
+			}
+			if len(got.Certificates) != len(tt.wantCerts) {
+				t.Fatalf("ParseCertFile() certificates count = %d, want %d", len(got.Certificates), len(tt.wantCerts))
+			}
+			for i := range tt.wantCerts {
+				if !got.Certificates[i].Equal(tt.wantCerts[i]) {
+					t.Errorf("ParseCertFile() certificate %d = %s, want %s", i, got.Certificates[i].Subject.CommonName, tt.wantCerts[i].Subject.CommonName)
+				}
+			}
+			if len(got.Requests) != tt.wantRequests {
+				t.Errorf("ParseCertFile() requests count = %d, want %d", len(got.Requests), tt.wantRequests)
+			}
+			if got.HasPrivateKey != tt.wantKey {
+				t.Errorf("ParseCertFile() HasPrivateKey = %v, want %v", got.HasPrivateKey, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestChecker_InspectCertificates(t *testing.T) {
+	leaf, intermediate, root := newTestChain(t, "example.com")
+	expired := newTestCert(t, testCertOptions{commonName: "expired.example.com", dnsNames: []string{"expired.example.com"},
+		notBefore: time.Now().Add(-48 * time.Hour), notAfter: time.Now().Add(-24 * time.Hour)}, intermediate)
+
+	tests := []struct {
+		name      string
+		certs     []*x509.Certificate
+		wantCodes []FindingCode
+		wantErr   bool
+	}{
+		{
+			name:      "test chain of private CA",
+			certs:     []*x509.Certificate{leaf.cert, intermediate.cert, root.cert},
+			wantCodes: []FindingCode{FindingUntrustedRoot},
+		},
+		{
+			name:      "test expired certificate",
+			certs:     []*x509.Certificate{expired.cert, intermediate.cert},
+			wantCodes: []FindingCode{FindingExpired, FindingMissingIntermediate},
+		},
+		{
+			name:    "test no certificates",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := &Checker{}
+			got := checker.InspectCertificates(context.Background(), "fullchain.pem", tt.certs)
+			if (got.Err != nil) != tt.wantErr {
+				t.Fatalf("InspectCertificates() error = %v, wantErr %v", got.Err, tt.wantErr)
+			}
+			if got.Target != "fullchain.pem" {
+				t.Errorf("InspectCertificates() target = %v, want fullchain.pem", got.Target)
+			}
+			if len(got.Chain) != len(tt.certs) {
+				t.Errorf("InspectCertificates() chain length = %d, want %d", len(got.Chain), len(tt.certs))
+			}
+			var gotCodes []FindingCode
+			for _, finding := range got.Findings {
+				if finding.Code == FindingHostnameMismatch {
+					t.Errorf("InspectCertificates() hostname is verified: %v", finding.Message)
+				}
+				gotCodes = append(gotCodes, finding.Code)
+			}
+			for _, code := range tt.wantCodes {
+				found := false
+				for _, gotCode := range gotCodes {
+					found = found || gotCode == code
+				}
+				if !found {
+					t.Errorf("InspectCertificates() findings = %v, want %v", gotCodes, code)
+				}
+			}
+		})
+	}
+}
+
+func TestChecker_InspectRequest(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	tampered := newTestRequest(t, key, "example.com")
+	tampered.Signature[len(tampered.Signature)-1] ^= 0xff
+
+	tests := []struct {
+		name      string
+		csr       *x509.CertificateRequest
+		wantCodes []FindingCode
+		wantKey   string
+	}{
+		{
+			name:    "test valid request",
+			csr:     newTestRequest(t, key, "example.com", "www.example.com"),
+			wantKey: "ECDSA P-256",
+		},
+		{
+			name:      "test weak RSA key",
+			csr:       newTestRequest(t, weakKey, "example.com"),
+			wantCodes: []FindingCode{FindingWeakKey},
+			wantKey:   "RSA 1024",
+		},
+		{
+			name:      "test invalid signature",
+			csr:       tampered,
+			wantCodes: []FindingCode{FindingInvalidRequestSignature},
+			wantKey:   "ECDSA P-256",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := &Checker{}
+			got := checker.InspectRequest(tt.csr)
+			var gotCodes []FindingCode
+			for _, finding := range got.Findings {
+				gotCodes = append(gotCodes, finding.Code)
+			}
+			if len(gotCodes) != len(tt.wantCodes) {
+				t.Fatalf("InspectRequest() findings = %v, want %v", gotCodes, tt.wantCodes)
+			}
+			for i := range tt.wantCodes {
+				if gotCodes[i] != tt.wantCodes[i] {
+					t.Errorf("InspectRequest() findings = %v, want %v", gotCodes, tt.wantCodes)
+				}
+			}
+			text := FormatRequestTelegram(got)
+			if !strings.Contains(text, "Key: "+tt.wantKey+",") {
+				t.Errorf("FormatRequestTelegram() = %v, want key %v", text, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestCleanFilePattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "test absolute path",
+			pattern: " /etc/nginx/ssl/../ssl/example.com.crt ",
+			want:    "/etc/nginx/ssl/example.com.crt",
+		},
+		{
+			name:    "test glob pattern",
+			pattern: "/etc/letsencrypt/live/*/fullchain.pem",
+			want:    "/etc/letsencrypt/live/*/fullchain.pem",
+		},
+		{
+			name:    "test relative path",
+			pattern: "ssl/example.com.crt",
+			wantErr: true,
+		},
+		{
+			name:    "test incorrect glob pattern",
+			pattern: "/etc/nginx/ssl/[.crt",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CleanFilePattern(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CleanFilePattern() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CleanFilePattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChecker_CheckFiles(t *testing.T) {
+	leaf, intermediate, _ := newTestChain(t, "example.com")
+	other, _, _ := newTestChain(t, "other.example.com")
+	keyDER, err := x509.MarshalPKCS8PrivateKey(leaf.key)
+	if err != nil {
+		t.Fatalf("cannot marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"example.com/fullchain.pem": append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.cert.Raw}),
+			pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: intermediate.cert.Raw})...),
+		"example.com/privkey.pem":    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		"other.example.com/cert.der": other.cert.Raw,
+		"broken/cert.pem":            pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("broken")}),
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("cannot create directory: %v", err)
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatalf("cannot write file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		pattern     string
+		wantTargets []string
+		wantCNs     []string
+		wantErr     bool
+	}{
+		{
+			name:        "test single file",
+			pattern:     filepath.Join(dir, "example.com/fullchain.pem"),
+			wantTargets: []string{filepath.Join(dir, "example.com/fullchain.pem")},
+			wantCNs:     []string{"example.com"},
+		},
+		{
+			name:        "test glob pattern skips private keys",
+			pattern:     filepath.Join(dir, "*.com/*"),
+			wantTargets: []string{filepath.Join(dir, "example.com/fullchain.pem"), filepath.Join(dir, "other.example.com/cert.der")},
+			wantCNs:     []string{"example.com", "other.example.com"},
+		},
+		{
+			name:        "test broken file",
+			pattern:     filepath.Join(dir, "broken/*"),
+			wantTargets: []string{filepath.Join(dir, "broken/cert.pem")},
+			wantCNs:     []string{""},
+		},
+		{
+			name:    "test private key file",
+			pattern: filepath.Join(dir, "example.com/privkey.pem"),
+			wantErr: true,
+		},
+		{
+			name:    "test no matched files",
+			pattern: filepath.Join(dir, "missing/*.pem"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := &Checker{}
+			got, err := checker.CheckFiles(context.Background(), tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.wantTargets) {
+				t.Fatalf("CheckFiles() reports count = %d, want %d", len(got), len(tt.wantTargets))
+			}
+			for i, report := range got {
+				if report.Target != tt.wantTargets[i] {
+					t.Errorf("CheckFiles() report %d target = %v, want %v", i, report.Target, tt.wantTargets[i])
+				}
+				if (report.Err != nil) != (tt.wantCNs[i] == "") {
+					t.Errorf("CheckFiles() report %d error = %v", i, report.Err)
+				}
+				if leaf := report.Leaf(); leaf != nil && leaf.CommonName != tt.wantCNs[i] {
+					t.Errorf("CheckFiles() report %d leaf = %v, want %v", i, leaf.CommonName, tt.wantCNs[i])
+				}
+			}
+		})
+	}
+}
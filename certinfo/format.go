@@ -0,0 +1,235 @@
+package certinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//FormatTelegram formats report for Telegram message
+//printFullChain - if false, CA certificates are skipped
+func FormatTelegram(report *CertReport, printFullChain bool) string {
+	if report.Err != nil {
+		return fmt.Sprintf("check certificate error - cannot check cert from URL %s. Error: %v\n\n", report.Target, report.Err)
+	}
+
+	result := ""
+	for _, cert := range report.Chain {
+		if !printFullChain && cert.IsCA {
+			continue
+		}
+		result += fmt.Sprintf("%s Check certificate for domain: %s\n", findingsMark(report.Findings), report.Target)
+		result += fmt.Sprintf("DNSNames: %s\n", cert.DNSNames)
+		result += fmt.Sprintf("Issuer Name: %s\n", cert.Issuer)
+		result += fmt.Sprintf("Expiry: %s\n", cert.NotAfter.Format("2006-01-02"))
+		result += fmt.Sprintf("Common Name: %s\n", cert.IssuerCommonName)
+		result += fmt.Sprintf("Key: %s, signature %s\n", keyDescription(cert), cert.SignatureAlgorithm)
+	}
+	if report.OCSP != nil {
+		result += fmt.Sprintf("OCSP: %s\n", ocspDescription(report.OCSP))
+	}
+	if report.CRL != nil {
+		result += fmt.Sprintf("CRL: %s\n", crlDescription(report.CRL))
+	}
+	if report.SCT != nil {
+		result += fmt.Sprintf("SCT: %s\n", sctDescription(report.SCT))
+	}
+	if report.CAA != nil {
+		result += fmt.Sprintf("CAA: %s\n", caaDescription(report.CAA))
+	}
+	if report.TLSA != nil {
+		result += fmt.Sprintf("TLSA: %s\n", tlsaDescription(report.TLSA))
+	}
+	for _, finding := range report.Findings {
+		result += finding.String() + "\n"
+	}
+	return result + "\n"
+}
+
+//FormatAuditTelegram formats audit report for Telegram message
+func FormatAuditTelegram(report *AuditReport) string {
+	if report.Err != nil {
+		return fmt.Sprintf("audit error - cannot audit TLS settings of %s. Error: %v\n\n", report.Target, report.Err)
+	}
+
+	result := fmt.Sprintf("%s TLS audit for domain: %s (%s)\n", findingsMark(report.Findings), report.Target, report.IP)
+	for _, version := range report.Versions {
+		if !version.Supported {
+			result += fmt.Sprintf("%s: not supported\n", version.Version)
+			continue
+		}
+		result += fmt.Sprintf("%s: %s\n", version.Version, strings.Join(version.CipherSuites, ", "))
+	}
+	if len(report.NotTested) > 0 {
+		result += fmt.Sprintf("Not tested: %s\n", strings.Join(report.NotTested, ", "))
+	}
+	if report.SCT != nil {
+		result += fmt.Sprintf("SCT: %s\n", sctDescription(report.SCT))
+	}
+	for _, finding := range report.Findings {
+		result += finding.String() + "\n"
+	}
+	return result + "\n"
+}
+
+//FormatRequestTelegram formats certificate signing request report for Telegram message
+func FormatRequestTelegram(report *RequestReport) string {
+	result := fmt.Sprintf("%s Check certificate signing request: %s\n", findingsMark(report.Findings), report.Subject)
+	result += fmt.Sprintf("DNSNames: %s\n", report.DNSNames)
+	if len(report.IPAddresses) > 0 {
+		result += fmt.Sprintf("IP Addresses: %s\n", report.IPAddresses)
+	}
+	key := CertDetails{PublicKeyAlgorithm: report.PublicKeyAlgorithm, KeySize: report.KeySize, KeyCurve: report.KeyCurve}
+	result += fmt.Sprintf("Key: %s, signature %s\n", keyDescription(key), report.SignatureAlgorithm)
+	for _, finding := range report.Findings {
+		result += finding.String() + "\n"
+	}
+	return result + "\n"
+}
+
+//FormatJSON formats reports to indented JSON
+func FormatJSON(reports []*CertReport) (string, error) {
+	result, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+//FormatCLI formats report as plain text for terminal output
+func FormatCLI(report *CertReport) string {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "Target:   %s\n", report.Target)
+	if report.Err != nil {
+		fmt.Fprintf(&builder, "Error:    [%s] %v\n", report.ErrorCategory, report.Err)
+		return builder.String()
+	}
+	fmt.Fprintf(&builder, "Endpoint: %s (%s)\n", report.Endpoint, report.IP)
+	fmt.Fprintf(&builder, "TLS:      %s, %s\n", report.TLSVersion, report.CipherSuite)
+	fmt.Fprintf(&builder, "Duration: %s\n", report.Duration)
+	builder.WriteString("Chain:\n")
+	for _, cert := range report.Chain {
+		fmt.Fprintf(&builder, "  [%d] %s\n", cert.Position, cert.Subject)
+		fmt.Fprintf(&builder, "      Issuer:    %s\n", cert.Issuer)
+		fmt.Fprintf(&builder, "      Validity:  %s - %s\n", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+		if len(cert.DNSNames) > 0 {
+			fmt.Fprintf(&builder, "      DNS names: %s\n", strings.Join(cert.DNSNames, ", "))
+		}
+		fmt.Fprintf(&builder, "      Key:       %s, signature %s\n", keyDescription(cert), cert.SignatureAlgorithm)
+		fmt.Fprintf(&builder, "      Serial:    %s\n", cert.SerialNumber)
+		fmt.Fprintf(&builder, "      SHA256:    %s\n", cert.SHA256Fingerprint)
+	}
+	if len(report.FetchedChain) > 0 {
+		builder.WriteString("Missing (fetched via AIA):\n")
+		for _, cert := range report.FetchedChain {
+			fmt.Fprintf(&builder, "  [%d] %s\n", cert.Position, cert.Subject)
+			fmt.Fprintf(&builder, "      Validity:  %s - %s\n", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+		}
+	}
+	if len(report.Nodes) > 1 {
+		builder.WriteString("Nodes:\n")
+		for _, node := range report.Nodes {
+			if node.Err != nil {
+				fmt.Fprintf(&builder, "  %s: [%s] %v\n", node.IP, node.ErrorCategory, node.Err)
+				continue
+			}
+			fmt.Fprintf(&builder, "  %s: serial %s, expiry %s\n", node.IP, certSerial(node.Chain), certExpiry(node.Chain))
+		}
+	}
+	if report.OCSP != nil {
+		fmt.Fprintf(&builder, "OCSP:     %s\n", ocspDescription(report.OCSP))
+	}
+	if report.CRL != nil {
+		fmt.Fprintf(&builder, "CRL:      %s\n", crlDescription(report.CRL))
+	}
+	if report.SCT != nil {
+		fmt.Fprintf(&builder, "SCT:      %s\n", sctDescription(report.SCT))
+	}
+	if report.CAA != nil {
+		fmt.Fprintf(&builder, "CAA:      %s\n", caaDescription(report.CAA))
+	}
+	if report.TLSA != nil {
+		fmt.Fprintf(&builder, "TLSA:     %s\n", tlsaDescription(report.TLSA))
+	}
+	if len(report.Findings) == 0 {
+		builder.WriteString("Findings: none\n")
+	} else {
+		builder.WriteString("Findings:\n")
+		for _, finding := range report.Findings {
+			fmt.Fprintf(&builder, "  [%s] %s: %s\n", finding.Severity, finding.Code, finding.Message)
+		}
+	}
+	return builder.String()
+}
+
+//keyDescription returns public key algorithm with key size or curve name
+func keyDescription(cert CertDetails) string {
+	switch {
+	case cert.KeyCurve != "":
+		return cert.PublicKeyAlgorithm + " " + cert.KeyCurve
+	case cert.KeySize > 0 && cert.PublicKeyAlgorithm == "RSA":
+		return fmt.Sprintf("%s %d", cert.PublicKeyAlgorithm, cert.KeySize)
+	}
+	return cert.PublicKeyAlgorithm
+}
+
+//ocspDescription returns OCSP status and stapling of OCSP response
+func ocspDescription(ocspReport *OCSPReport) string {
+	status := ocspReport.Status
+	if status == "" {
+		status = "not checked"
+	}
+	if ocspReport.Stapled {
+		return status + ", stapled"
+	}
+	return status + ", not stapled"
+}
+
+//crlDescription returns CRL status and distribution point
+func crlDescription(crlReport *CRLReport) string {
+	status := crlReport.Status
+	if status == "" {
+		status = "not checked"
+	}
+	return fmt.Sprintf("%s (%s)", status, crlReport.URL)
+}
+
+//sctDescription returns count of valid SCTs and distinct logs
+func sctDescription(sctReport *SCTReport) string {
+	return fmt.Sprintf("%d valid from %d logs (%d found)", sctReport.Valid, len(sctReport.Logs), sctReport.Found)
+}
+
+//caaDescription returns permitted issuers and domain name of relevant CAA records
+func caaDescription(caaReport *CAAReport) string {
+	if caaReport.Domain == "" {
+		return "no records"
+	}
+	issuers := strings.Join(caaReport.Issuers, ", ")
+	if issuers == "" {
+		issuers = "no CA is permitted"
+	}
+	return fmt.Sprintf("%s (%s)", issuers, caaReport.Domain)
+}
+
+//tlsaDescription returns matching TLSA record and name of TLSA records
+func tlsaDescription(tlsaReport *TLSAReport) string {
+	if tlsaReport.Matched == nil {
+		return fmt.Sprintf("no matching record (%s)", tlsaReport.Name)
+	}
+	return fmt.Sprintf("matches %s (%s)", tlsaReport.Matched, tlsaReport.Name)
+}
+
+//findingsMark returns mark of the most severe finding
+func findingsMark(findings []Finding) string {
+	mark := "✅"
+	for _, finding := range findings {
+		switch finding.Severity {
+		case SeverityCritical:
+			return "❌"
+		case SeverityWarning:
+			mark = "⚠️"
+		}
+	}
+	return mark
+}
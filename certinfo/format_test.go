@@ -0,0 +1,335 @@
+package certinfo
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func getTestReport() *CertReport {
+	return &CertReport{
+		Target:      "example.com",
+		Endpoint:    "example.com:443",
+		IP:          "93.184.216.34",
+		TLSVersion:  "TLS 1.3",
+		CipherSuite: "TLS_AES_128_GCM_SHA256",
+		Chain: []CertDetails{
+			{
+				Position:           0,
+				Subject:            "CN=example.com",
+				CommonName:         "example.com",
+				DNSNames:           []string{"example.com", "www.example.com"},
+				Issuer:             "CN=Test Intermediate CA",
+				IssuerCommonName:   "Test Intermediate CA",
+				SerialNumber:       "0A",
+				NotBefore:          time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+				NotAfter:           time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+				SignatureAlgorithm: "ECDSA-SHA384",
+				PublicKeyAlgorithm: "RSA",
+				KeySize:            2048,
+				SHA256Fingerprint:  "AB",
+			},
+			{
+				Position:           1,
+				Subject:            "CN=Test Intermediate CA",
+				CommonName:         "Test Intermediate CA",
+				Issuer:             "CN=Test Root CA",
+				IssuerCommonName:   "Test Root CA",
+				SerialNumber:       "0B",
+				NotBefore:          time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+				NotAfter:           time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+				IsCA:               true,
+				SignatureAlgorithm: "SHA256-RSA",
+				PublicKeyAlgorithm: "ECDSA",
+				KeySize:            384,
+				KeyCurve:           "P-384",
+				SHA256Fingerprint:  "CD",
+			},
+		},
+		Findings: []Finding{
+			{Code: FindingMissingIntermediate, Severity: SeverityWarning, Message: "certificate chain is incomplete"},
+		},
+		StartedAt: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+		Duration:  150 * time.Millisecond,
+	}
+}
+
+func getTestErrorReport() *CertReport {
+	report := &CertReport{Target: "notValidDomain"}
+	report.setError(ErrorCategoryDNS, errors.New("lookup notValidDomain: no such host"))
+	return report
+}
+
+func TestFormatTelegram(t *testing.T) {
+	tests := []struct {
+		name           string
+		report         *CertReport
+		printFullChain bool
+		want           string
+	}{
+		{
+			name:   "test leaf only",
+			report: getTestReport(),
+			want: "⚠️ Check certificate for domain: example.com\n" +
+				"DNSNames: [example.com www.example.com]\n" +
+				"Issuer Name: CN=Test Intermediate CA\n" +
+				"Expiry: 2023-01-01\n" +
+				"Common Name: Test Intermediate CA\n" +
+				"Key: RSA 2048, signature ECDSA-SHA384\n" +
+				"⚠️ certificate chain is incomplete\n\n",
+		},
+		{
+			name:           "test full chain",
+			report:         getTestReport(),
+			printFullChain: true,
+			want: "⚠️ Check certificate for domain: example.com\n" +
+				"DNSNames: [example.com www.example.com]\n" +
+				"Issuer Name: CN=Test Intermediate CA\n" +
+				"Expiry: 2023-01-01\n" +
+				"Common Name: Test Intermediate CA\n" +
+				"Key: RSA 2048, signature ECDSA-SHA384\n" +
+				"⚠️ Check certificate for domain: example.com\n" +
+				"DNSNames: []\n" +
+				"Issuer Name: CN=Test Root CA\n" +
+				"Expiry: 2030-01-01\n" +
+				"Common Name: Test Root CA\n" +
+				"Key: ECDSA P-384, signature SHA256-RSA\n" +
+				"⚠️ certificate chain is incomplete\n\n",
+		},
+		{
+			name: "test OCSP status",
+			report: func() *CertReport {
+				report := getTestReport()
+				report.OCSP = &OCSPReport{ResponderURL: "http://ocsp.example.com", Source: OCSPSourceResponder, Status: OCSPStatusRevoked}
+				report.SCT = &SCTReport{Found: 3, Valid: 2, Logs: []string{"Test log 1", "Test log 2"}, Required: 2}
+				report.CAA = &CAAReport{Domain: "example.com", Issuers: []string{"letsencrypt.org", "pki.goog"}, Authorized: true}
+				report.TLSA = &TLSAReport{Name: "_443._tcp.example.com", Records: []TLSARecord{{Usage: 3, Selector: 1, MatchingType: 1, Data: "8CB0FC6C527506A053F4F14C8464BEBBD6DEDE2738D11468DD953D7D6A3021F1"}}}
+				report.Findings = []Finding{{Code: FindingRevoked, Severity: SeverityCritical, Message: "leaf certificate example.com is revoked"}}
+				return report
+			}(),
+			want: "❌ Check certificate for domain: example.com\n" +
+				"DNSNames: [example.com www.example.com]\n" +
+				"Issuer Name: CN=Test Intermediate CA\n" +
+				"Expiry: 2023-01-01\n" +
+				"Common Name: Test Intermediate CA\n" +
+				"Key: RSA 2048, signature ECDSA-SHA384\n" +
+				"OCSP: revoked, not stapled\n" +
+				"SCT: 2 valid from 2 logs (3 found)\n" +
+				"CAA: letsencrypt.org, pki.goog (example.com)\n" +
+				"TLSA: no matching record (_443._tcp.example.com)\n" +
+				"❌ leaf certificate example.com is revoked\n\n",
+		},
+		{
+			name:   "test error",
+			report: getTestErrorReport(),
+			want:   "check certificate error - cannot check cert from URL notValidDomain. Error: lookup notValidDomain: no such host\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatTelegram(tt.report, tt.printFullChain); got != tt.want {
+				t.Errorf("FormatTelegram() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatAuditTelegram(t *testing.T) {
+	errorReport := &AuditReport{Target: "notValidDomain"}
+	errorReport.setError(ErrorCategoryDNS, errors.New("lookup notValidDomain: no such host"))
+
+	tests := []struct {
+		name   string
+		report *AuditReport
+		want   string
+	}{
+		{
+			name: "test audit",
+			report: &AuditReport{
+				Target: "example.com",
+				IP:     "93.184.216.34",
+				Versions: []VersionAudit{
+					{Version: "TLS 1.0"},
+					{Version: "TLS 1.2", Supported: true, CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_RSA_WITH_AES_128_GCM_SHA256"}},
+				},
+				NotTested: []string{"SSL 3.0", "DHE cipher suites"},
+				SCT:       &SCTReport{Found: 2, Valid: 2, Logs: []string{"Test log 1", "Test log 2"}, Required: 2},
+				Findings: []Finding{
+					{Code: FindingWeakCipherSuite, Severity: SeverityWarning, Message: "weak cipher suite TLS_RSA_WITH_AES_128_GCM_SHA256 is accepted (no forward secrecy)"},
+				},
+			},
+			want: "⚠️ TLS audit for domain: example.com (93.184.216.34)\n" +
+				"TLS 1.0: not supported\n" +
+				"TLS 1.2: TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_RSA_WITH_AES_128_GCM_SHA256\n" +
+				"Not tested: SSL 3.0, DHE cipher suites\n" +
+				"SCT: 2 valid from 2 logs (2 found)\n" +
+				"⚠️ weak cipher suite TLS_RSA_WITH_AES_128_GCM_SHA256 is accepted (no forward secrecy)\n\n",
+		},
+		{
+			name:   "test error",
+			report: errorReport,
+			want:   "audit error - cannot audit TLS settings of notValidDomain. Error: lookup notValidDomain: no such host\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatAuditTelegram(tt.report); got != tt.want {
+				t.Errorf("FormatAuditTelegram() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	reports := []*CertReport{getTestReport(), getTestErrorReport()}
+
+	got, err := FormatJSON(reports)
+	if err != nil {
+		t.Errorf("FormatJSON() error = %v", err)
+		return
+	}
+
+	var parsed []map[string]interface{}
+	if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+		t.Errorf("FormatJSON() returns incorrect JSON: %v", err)
+		return
+	}
+	if len(parsed) != 2 {
+		t.Errorf("FormatJSON() reports count = %d, want 2", len(parsed))
+		return
+	}
+	if parsed[0]["target"] != "example.com" || parsed[0]["tls_version"] != "TLS 1.3" {
+		t.Errorf("FormatJSON() incorrect report fields: %v", parsed[0])
+	}
+	chain, _ := parsed[0]["chain"].([]interface{})
+	if len(chain) != 2 {
+		t.Errorf("FormatJSON() chain length = %d, want 2", len(chain))
+	}
+	findings, _ := parsed[0]["findings"].([]interface{})
+	wantFinding := map[string]interface{}{"code": "missing_intermediate", "severity": "warning", "message": "certificate chain is incomplete"}
+	if len(findings) != 1 || !reflect.DeepEqual(findings[0], wantFinding) {
+		t.Errorf("FormatJSON() findings = %v, want %v", findings, wantFinding)
+	}
+	if parsed[1]["error_category"] != "dns" || parsed[1]["error"] != "lookup notValidDomain: no such host" {
+		t.Errorf("FormatJSON() incorrect error report: %v", parsed[1])
+	}
+}
+
+func TestFormatCLI(t *testing.T) {
+	nodesReport := getTestReport()
+	nodesReport.Nodes = []NodeReport{
+		{IP: "93.184.216.34", Chain: nodesReport.Chain},
+		{IP: "93.184.216.35", Chain: []CertDetails{{SerialNumber: "09", NotAfter: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)}}},
+		{IP: "93.184.216.36", ErrorCategory: ErrorCategoryConnect, Err: errors.New("connection refused")},
+	}
+	nodesReport.Findings = nil
+	nodesReport.OCSP = &OCSPReport{Stapled: true, Source: OCSPSourceStaple, Status: OCSPStatusGood}
+	nodesReport.CRL = &CRLReport{URL: "http://crl.example.com/ca.crl", Status: CRLStatusGood}
+	nodesReport.SCT = &SCTReport{Found: 1, Valid: 1, Logs: []string{"Test log 1"}, Required: 2}
+	nodesReport.CAA = &CAAReport{Authorized: true}
+	nodesReport.TLSA = &TLSAReport{Name: "_443._tcp.example.com", Records: []TLSARecord{{Usage: 2, Selector: 0, MatchingType: 1, Data: "8CB0FC6C527506A053F4F14C8464BEBBD6DEDE2738D11468DD953D7D6A3021F1"}}}
+	nodesReport.TLSA.Matched = &nodesReport.TLSA.Records[0]
+	incompleteReport := getTestReport()
+	incompleteReport.FetchedChain = incompleteReport.Chain[1:]
+	incompleteReport.Chain = incompleteReport.Chain[:1]
+	incompleteReport.Findings = []Finding{{Code: FindingIncompleteChain, Severity: SeverityWarning, Message: "incomplete chain, missing Test Intermediate CA"}}
+
+	tests := []struct {
+		name   string
+		report *CertReport
+		want   string
+	}{
+		{
+			name:   "test report",
+			report: getTestReport(),
+			want: "Target:   example.com\n" +
+				"Endpoint: example.com:443 (93.184.216.34)\n" +
+				"TLS:      TLS 1.3, TLS_AES_128_GCM_SHA256\n" +
+				"Duration: 150ms\n" +
+				"Chain:\n" +
+				"  [0] CN=example.com\n" +
+				"      Issuer:    CN=Test Intermediate CA\n" +
+				"      Validity:  2022-01-01 - 2023-01-01\n" +
+				"      DNS names: example.com, www.example.com\n" +
+				"      Key:       RSA 2048, signature ECDSA-SHA384\n" +
+				"      Serial:    0A\n" +
+				"      SHA256:    AB\n" +
+				"  [1] CN=Test Intermediate CA\n" +
+				"      Issuer:    CN=Test Root CA\n" +
+				"      Validity:  2020-01-01 - 2030-01-01\n" +
+				"      Key:       ECDSA P-384, signature SHA256-RSA\n" +
+				"      Serial:    0B\n" +
+				"      SHA256:    CD\n" +
+				"Findings:\n" +
+				"  [warning] missing_intermediate: certificate chain is incomplete\n",
+		},
+		{
+			name:   "test report with nodes",
+			report: nodesReport,
+			want: "Target:   example.com\n" +
+				"Endpoint: example.com:443 (93.184.216.34)\n" +
+				"TLS:      TLS 1.3, TLS_AES_128_GCM_SHA256\n" +
+				"Duration: 150ms\n" +
+				"Chain:\n" +
+				"  [0] CN=example.com\n" +
+				"      Issuer:    CN=Test Intermediate CA\n" +
+				"      Validity:  2022-01-01 - 2023-01-01\n" +
+				"      DNS names: example.com, www.example.com\n" +
+				"      Key:       RSA 2048, signature ECDSA-SHA384\n" +
+				"      Serial:    0A\n" +
+				"      SHA256:    AB\n" +
+				"  [1] CN=Test Intermediate CA\n" +
+				"      Issuer:    CN=Test Root CA\n" +
+				"      Validity:  2020-01-01 - 2030-01-01\n" +
+				"      Key:       ECDSA P-384, signature SHA256-RSA\n" +
+				"      Serial:    0B\n" +
+				"      SHA256:    CD\n" +
+				"Nodes:\n" +
+				"  93.184.216.34: serial 0A, expiry 2023-01-01\n" +
+				"  93.184.216.35: serial 09, expiry 2022-06-01\n" +
+				"  93.184.216.36: [connect] connection refused\n" +
+				"OCSP:     good, stapled\n" +
+				"CRL:      good (http://crl.example.com/ca.crl)\n" +
+				"SCT:      1 valid from 1 logs (1 found)\n" +
+				"CAA:      no records\n" +
+				"TLSA:     matches 2 0 1 8CB0FC6C527506A0... (_443._tcp.example.com)\n" +
+				"Findings: none\n",
+		},
+		{
+			name:   "test report with incomplete chain",
+			report: incompleteReport,
+			want: "Target:   example.com\n" +
+				"Endpoint: example.com:443 (93.184.216.34)\n" +
+				"TLS:      TLS 1.3, TLS_AES_128_GCM_SHA256\n" +
+				"Duration: 150ms\n" +
+				"Chain:\n" +
+				"  [0] CN=example.com\n" +
+				"      Issuer:    CN=Test Intermediate CA\n" +
+				"      Validity:  2022-01-01 - 2023-01-01\n" +
+				"      DNS names: example.com, www.example.com\n" +
+				"      Key:       RSA 2048, signature ECDSA-SHA384\n" +
+				"      Serial:    0A\n" +
+				"      SHA256:    AB\n" +
+				"Missing (fetched via AIA):\n" +
+				"  [1] CN=Test Intermediate CA\n" +
+				"      Validity:  2020-01-01 - 2030-01-01\n" +
+				"Findings:\n" +
+				"  [warning] incomplete_chain: incomplete chain, missing Test Intermediate CA\n",
+		},
+		{
+			name:   "test error",
+			report: getTestErrorReport(),
+			want: "Target:   notValidDomain\n" +
+				"Error:    [dns] lookup notValidDomain: no such host\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatCLI(tt.report); got != tt.want {
+				t.Errorf("FormatCLI() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package certinfo
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+//Key policy findings
+const (
+	FindingWeakKey          FindingCode = "weak_key"
+	FindingWeakSignature    FindingCode = "weak_signature"
+	FindingUnusualCurve     FindingCode = "unusual_curve"
+	FindingMissingServerEKU FindingCode = "missing_server_auth_eku"
+	FindingLongValidity     FindingCode = "long_validity"
+)
+
+//Default key policy thresholds
+const (
+	DefaultMinRSABits      = 2048
+	DefaultMaxValidityDays = 398
+)
+
+//DefaultAllowedCurves - default curves of ECDSA keys, which are not reported as unusual
+var DefaultAllowedCurves = []string{"P-256", "P-384", "P-521"}
+
+//KeyPolicy thresholds of key and signature checks
+type KeyPolicy struct {
+	//MinRSABits - min size of RSA keys. If 0 - DefaultMinRSABits is used
+	MinRSABits int
+	//MaxValidityDays - max validity period of leaf certificate in days. If 0 - DefaultMaxValidityDays is used
+	MaxValidityDays int
+	//AllowedCurves - names of ECDSA curves, which are not reported as unusual. If empty - DefaultAllowedCurves is used
+	AllowedCurves []string
+}
+
+func (p KeyPolicy) minRSABits() int {
+	if p.MinRSABits > 0 {
+		return p.MinRSABits
+	}
+	return DefaultMinRSABits
+}
+
+func (p KeyPolicy) maxValidityDays() int {
+	if p.MaxValidityDays > 0 {
+		return p.MaxValidityDays
+	}
+	return DefaultMaxValidityDays
+}
+
+func (p KeyPolicy) allowedCurves() []string {
+	if len(p.AllowedCurves) > 0 {
+		return p.AllowedCurves
+	}
+	return DefaultAllowedCurves
+}
+
+//CheckKeyPolicy checks keys and signature algorithms of served chain, extended key usage and validity period of leaf
+//certs - served chain, first certificate must be a leaf
+func CheckKeyPolicy(certs []*x509.Certificate, policy KeyPolicy) []Finding {
+	var findings []Finding
+	for i, cert := range certs {
+		name := fmt.Sprintf("%s %s", chainPositionName(i), cert.Subject.CommonName)
+
+		findings = append(findings, keyFindings(name, cert.PublicKey, cert.PublicKeyAlgorithm, policy)...)
+
+		//signature of self-signed root is not verified by clients
+		if isWeakSignature(cert.SignatureAlgorithm) && !isSelfSigned(cert) {
+			findings = append(findings, Finding{
+				Code:     FindingWeakSignature,
+				Severity: SeverityCritical,
+				Message:  fmt.Sprintf("%s is signed with weak algorithm %s", name, cert.SignatureAlgorithm),
+			})
+		}
+	}
+	if len(certs) == 0 {
+		return findings
+	}
+
+	leaf := certs[0]
+	if !hasServerAuth(leaf) {
+		findings = append(findings, Finding{
+			Code:     FindingMissingServerEKU,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("leaf certificate %s has no serverAuth extended key usage", leaf.Subject.CommonName),
+		})
+	}
+	validityDays := int(leaf.NotAfter.Sub(leaf.NotBefore) / (24 * time.Hour))
+	if validityDays > policy.maxValidityDays() {
+		findings = append(findings, Finding{
+			Code:     FindingLongValidity,
+			Severity: SeverityWarning,
+			Message: fmt.Sprintf("leaf certificate %s validity period is %d days, max %d days expected",
+				leaf.Subject.CommonName, validityDays, policy.maxValidityDays()),
+		})
+	}
+	return findings
+}
+
+//keyFindings checks size of RSA key and curve of ECDSA key
+//name - name of key owner for finding messages
+func keyFindings(name string, key crypto.PublicKey, keyAlgorithm x509.PublicKeyAlgorithm, policy KeyPolicy) []Finding {
+	algorithm, size, curve := keyInfo(key, keyAlgorithm)
+	switch {
+	case algorithm == "RSA" && size < policy.minRSABits():
+		return []Finding{{
+			Code:     FindingWeakKey,
+			Severity: SeverityCritical,
+			Message:  fmt.Sprintf("%s has weak RSA key %d bits, min %d bits expected", name, size, policy.minRSABits()),
+		}}
+	case algorithm == "ECDSA" && !stringInSlice(curve, policy.allowedCurves()):
+		return []Finding{{
+			Code:     FindingUnusualCurve,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("%s has ECDSA key on unusual curve %s", name, curve),
+		}}
+	}
+	return nil
+}
+
+//publicKeyInfo returns public key algorithm, key size in bits and curve name of ECDSA key
+func publicKeyInfo(cert *x509.Certificate) (algorithm string, size int, curve string) {
+	return keyInfo(cert.PublicKey, cert.PublicKeyAlgorithm)
+}
+
+//keyInfo returns public key algorithm, key size in bits and curve name of ECDSA key
+func keyInfo(key crypto.PublicKey, keyAlgorithm x509.PublicKeyAlgorithm) (algorithm string, size int, curve string) {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen(), ""
+	case *ecdsa.PublicKey:
+		return "ECDSA", key.Curve.Params().BitSize, key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519", 256, ""
+	}
+	return keyAlgorithm.String(), 0, ""
+}
+
+//isWeakSignature returns true for signature algorithms based on MD2, MD5 or SHA-1
+func isWeakSignature(algorithm x509.SignatureAlgorithm) bool {
+	switch algorithm {
+	case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+		return true
+	}
+	return false
+}
+
+//hasServerAuth checks that certificate can be used for TLS server authentication
+func hasServerAuth(cert *x509.Certificate) bool {
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageServerAuth || usage == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package certinfo
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//newPolicyTestCert creates certificate with key, signature algorithm, extended key usages and validity period
+//if parent is nil - certificate is self-signed
+func newPolicyTestCert(t *testing.T, key crypto.Signer, algorithm x509.SignatureAlgorithm, usages []x509.ExtKeyUsage,
+	validity time.Duration, parent *x509.Certificate, parentKey crypto.Signer) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber:       big.NewInt(time.Now().UnixNano()),
+		Subject:            pkix.Name{CommonName: "policy.example.com"},
+		NotBefore:          time.Now().Add(-time.Hour),
+		NotAfter:           time.Now().Add(validity),
+		SignatureAlgorithm: algorithm,
+		ExtKeyUsage:        usages,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %v", err)
+	}
+	return cert
+}
+
+func TestCheckKeyPolicy(t *testing.T) {
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p224Key, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	rsa1024Key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	rsa2048Key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	serverAuth := []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name   string
+		certs  []*x509.Certificate
+		policy KeyPolicy
+		want   []FindingCode
+	}{
+		{
+			name:  "test strong ECDSA certificate",
+			certs: []*x509.Certificate{newPolicyTestCert(t, p256Key, x509.ECDSAWithSHA256, serverAuth, 90*day, nil, nil)},
+		},
+		{
+			name:  "test strong RSA certificate",
+			certs: []*x509.Certificate{newPolicyTestCert(t, rsa2048Key, x509.SHA256WithRSA, serverAuth, 90*day, nil, nil)},
+		},
+		{
+			name:  "test weak RSA key",
+			certs: []*x509.Certificate{newPolicyTestCert(t, rsa1024Key, x509.SHA256WithRSA, serverAuth, 90*day, nil, nil)},
+			want:  []FindingCode{FindingWeakKey},
+		},
+		{
+			name:   "test RSA key with custom min size",
+			certs:  []*x509.Certificate{newPolicyTestCert(t, rsa2048Key, x509.SHA256WithRSA, serverAuth, 90*day, nil, nil)},
+			policy: KeyPolicy{MinRSABits: 3072},
+			want:   []FindingCode{FindingWeakKey},
+		},
+		{
+			name:  "test unusual curve",
+			certs: []*x509.Certificate{newPolicyTestCert(t, p224Key, x509.ECDSAWithSHA256, serverAuth, 90*day, nil, nil)},
+			want:  []FindingCode{FindingUnusualCurve},
+		},
+		{
+			name:   "test allowed curves",
+			certs:  []*x509.Certificate{newPolicyTestCert(t, p224Key, x509.ECDSAWithSHA256, serverAuth, 90*day, nil, nil)},
+			policy: KeyPolicy{AllowedCurves: []string{"P-224", "P-256"}},
+		},
+		{
+			name: "test SHA-1 signature",
+			certs: []*x509.Certificate{
+				newPolicyTestCert(t, p256Key, x509.SHA1WithRSA, serverAuth, 90*day,
+					newPolicyTestCert(t, rsa2048Key, x509.SHA256WithRSA, nil, 365*day, nil, nil), rsa2048Key),
+			},
+			want: []FindingCode{FindingWeakSignature},
+		},
+		{
+			name: "test SHA-1 signature of self-signed root",
+			certs: []*x509.Certificate{
+				newPolicyTestCert(t, p256Key, x509.ECDSAWithSHA256, serverAuth, 90*day, nil, nil),
+				newPolicyTestCert(t, rsa2048Key, x509.SHA1WithRSA, nil, 365*day, nil, nil),
+			},
+		},
+		{
+			name:  "test missing serverAuth extended key usage",
+			certs: []*x509.Certificate{newPolicyTestCert(t, p256Key, x509.ECDSAWithSHA256, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, 90*day, nil, nil)},
+			want:  []FindingCode{FindingMissingServerEKU},
+		},
+		{
+			name:  "test long validity",
+			certs: []*x509.Certificate{newPolicyTestCert(t, p256Key, x509.ECDSAWithSHA256, serverAuth, 825*day, nil, nil)},
+			want:  []FindingCode{FindingLongValidity},
+		},
+		{
+			name:   "test validity with custom max validity",
+			certs:  []*x509.Certificate{newPolicyTestCert(t, p256Key, x509.ECDSAWithSHA256, serverAuth, 90*day, nil, nil)},
+			policy: KeyPolicy{MaxValidityDays: 30},
+			want:   []FindingCode{FindingLongValidity},
+		},
+		{
+			name: "test empty chain",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findingsCodes(CheckKeyPolicy(tt.certs, tt.policy)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckKeyPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
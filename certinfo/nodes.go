@@ -0,0 +1,241 @@
+package certinfo
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+//Resolver resolves host addresses. *net.Resolver implements Resolver
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+//NodeReport result of check of single IP address behind target
+type NodeReport struct {
+	//IP - checked IP address, or host name if host is resolved by proxy
+	IP          string        `json:"ip"`
+	TLSVersion  string        `json:"tls_version,omitempty"`
+	CipherSuite string        `json:"cipher_suite,omitempty"`
+	Chain       []CertDetails `json:"chain,omitempty"`
+
+	ErrorCategory ErrorCategory `json:"error_category,omitempty"`
+	ErrorMessage  string        `json:"error,omitempty"`
+	Err           error         `json:"-"`
+}
+
+//leafFingerprint returns SHA-256 fingerprint of node leaf certificate, empty if node has no certificates
+func (n *NodeReport) leafFingerprint() string {
+	if len(n.Chain) == 0 {
+		return ""
+	}
+	return n.Chain[0].SHA256Fingerprint
+}
+
+func (c *Checker) resolver() Resolver {
+	if c.Resolver != nil {
+		return c.Resolver
+	}
+	return net.DefaultResolver
+}
+
+//resolve returns all IP addresses of target host
+//If target IP is specified or host is IP address - returns it
+//If host is resolved by proxy - returns host name
+func (c *Checker) resolve(ctx context.Context, target *Target) ([]string, error) {
+	if target.IP != "" {
+		return []string{target.IP}, nil
+	}
+	host := target.Host
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{ip.String()}, nil
+	}
+	if proxy := c.proxyFor(host, ""); proxy != nil && proxy.resolvesRemotely() {
+		return []string{host}, nil
+	}
+
+	resolveCtx, cancel := context.WithTimeout(ctx, c.connectTimeout())
+	defer cancel()
+	addrs, err := c.resolver().LookupIPAddr(resolveCtx, host)
+	if err == nil && len(addrs) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	if err != nil {
+		return nil, dialError(ctx, err, ErrorCategoryDNS, "resolving "+host)
+	}
+
+	var ips []string
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP.String())
+	}
+	return ips, nil
+}
+
+//checkNodes checks every IP address of target concurrently
+//returns nodes reports and served chains
+func (c *Checker) checkNodes(ctx context.Context, target *Target, ips []string) ([]NodeReport, []*tls.ConnectionState) {
+	nodes := make([]NodeReport, len(ips))
+	states := make([]*tls.ConnectionState, len(ips))
+	var wg sync.WaitGroup
+	for i, ip := range ips {
+		wg.Add(1)
+		go func(i int, ip string) {
+			defer wg.Done()
+			nodes[i], states[i] = c.checkNode(ctx, target, ip)
+		}(i, ip)
+	}
+	wg.Wait()
+	return nodes, states
+}
+
+//checkNode checks single IP address of target, host of target is used as SNI
+func (c *Checker) checkNode(ctx context.Context, target *Target, ip string) (NodeReport, *tls.ConnectionState) {
+	node := NodeReport{IP: ip}
+
+	conn, err := c.dialTarget(ctx, target, net.JoinHostPort(ip, target.Port), nil)
+	if err != nil {
+		log.Println("Error in Dial", err)
+		category, err := checkErrorCategory(err)
+		node.ErrorCategory = category
+		node.ErrorMessage = err.Error()
+		node.Err = err
+		return node, nil
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	node.TLSVersion = tlsVersionName(state.Version)
+	node.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	for i, cert := range state.PeerCertificates {
+		node.Chain = append(node.Chain, newCertDetails(i, cert))
+	}
+	return node, &state
+}
+
+//referenceNode returns index of node, which certificate is served by most nodes
+//returns -1 if all nodes are failed
+func referenceNode(nodes []NodeReport) int {
+	counts := map[string]int{}
+	for _, node := range nodes {
+		if node.Err == nil {
+			counts[node.leafFingerprint()]++
+		}
+	}
+	reference := -1
+	for i, node := range nodes {
+		if node.Err != nil {
+			continue
+		}
+		if reference == -1 || counts[node.leafFingerprint()] > counts[nodes[reference].leafFingerprint()] {
+			reference = i
+		}
+	}
+	return reference
+}
+
+//nodesFindings returns findings for unreachable nodes and nodes, which serve different certificate than reference node
+func nodesFindings(nodes []NodeReport, reference int) []Finding {
+	var findings []Finding
+	referenceFingerprint := nodes[reference].leafFingerprint()
+	for _, node := range nodes {
+		if node.Err != nil {
+			findings = append(findings, Finding{
+				Code:     FindingNodeUnreachable,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("node %s cannot be checked: %v", node.IP, node.Err),
+			})
+			continue
+		}
+		if node.leafFingerprint() != referenceFingerprint {
+			findings = append(findings, Finding{
+				Code:     FindingNodeCertMismatch,
+				Severity: SeverityCritical,
+				Message: fmt.Sprintf("node %s serves different certificate (serial %s, expiry %s) than node %s (serial %s, expiry %s)",
+					node.IP, certSerial(node.Chain), certExpiry(node.Chain),
+					nodes[reference].IP, certSerial(nodes[reference].Chain), certExpiry(nodes[reference].Chain)),
+			})
+		}
+	}
+	return findings
+}
+
+func certSerial(chain []CertDetails) string {
+	if len(chain) == 0 {
+		return "-"
+	}
+	return chain[0].SerialNumber
+}
+
+func certExpiry(chain []CertDetails) string {
+	if len(chain) == 0 {
+		return "-"
+	}
+	return chain[0].NotAfter.Format("2006-01-02")
+}
+
+//checkNodesTarget resolves target and checks every node, fills report
+func (c *Checker) checkNodesTarget(ctx context.Context, target *Target, report *CertReport) {
+	ips, err := c.resolve(ctx, target)
+	if err != nil {
+		log.Println("Error in Dial", err)
+		report.setCheckError(err)
+		return
+	}
+
+	nodes, states := c.checkNodes(ctx, target, ips)
+	report.Nodes = nodes
+
+	reference := referenceNode(nodes)
+	if reference == -1 {
+		report.setError(nodes[0].ErrorCategory, nodes[0].Err)
+		return
+	}
+
+	node := nodes[reference]
+	report.IP = node.IP
+	report.TLSVersion = node.TLSVersion
+	report.CipherSuite = node.CipherSuite
+	report.Chain = node.Chain
+	report.Findings = VerifyChain(states[reference].PeerCertificates, target.Host, c.Roots, time.Now())
+	fetched, findings := c.checkChainCompleteness(ctx, states[reference].PeerCertificates, report.Findings, time.Now())
+	report.Findings = findings
+	for i, cert := range fetched {
+		report.FetchedChain = append(report.FetchedChain, newCertDetails(len(report.Chain)+i, cert))
+	}
+	report.Findings = append(report.Findings, CheckKeyPolicy(states[reference].PeerCertificates, c.KeyPolicy)...)
+	report.Findings = append(report.Findings, nodesFindings(nodes, reference)...)
+
+	certs := states[reference].PeerCertificates
+	if len(certs) > 0 {
+		c.checkLeafStatus(ctx, report, certs, fetched, states[reference].OCSPResponse, states[reference].SignedCertificateTimestamps)
+		report.CAA, findings = c.CheckCAA(ctx, target.Host, certs[0])
+		report.Findings = append(report.Findings, findings...)
+		report.TLSA, findings = c.CheckTLSA(ctx, target.Host, target.Port, certs, pkixValid(report.Findings), time.Now())
+		report.Findings = append(report.Findings, findings...)
+	}
+}
+
+//checkLeafStatus checks revocation status and SCTs of leaf certificate, fills report
+//certs - served chain, fetched - certificates fetched via AIA
+//ocspResponse and scts - stapled OCSP response and SCTs from TLS extension, nil if not sent
+func (c *Checker) checkLeafStatus(ctx context.Context, report *CertReport, certs []*x509.Certificate, fetched []*x509.Certificate,
+	ocspResponse []byte, scts [][]byte) {
+	//issuer fetched via AIA is used, if server does not send it
+	issuer := chainIssuer(append(append([]*x509.Certificate{}, certs...), fetched...))
+	var findings []Finding
+	report.OCSP, findings = c.CheckOCSP(ctx, certs[0], issuer, ocspResponse, time.Now())
+	report.Findings = append(report.Findings, findings...)
+	//CRL is checked only if OCSP status is not known
+	if report.OCSP == nil || report.OCSP.Status == "" {
+		report.CRL, findings = c.CheckCRL(ctx, certs[0], issuer, time.Now())
+		report.Findings = append(report.Findings, findings...)
+	}
+	report.SCT, findings = c.CheckSCTs(certs[0], issuer, scts, ocspResponse,
+		publiclyTrusted(append(append([]*x509.Certificate{}, certs...), fetched...), time.Now()), time.Now())
+	report.Findings = append(report.Findings, findings...)
+}
@@ -0,0 +1,190 @@
+package certinfo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//testResolver resolves every host to configured addresses
+type testResolver struct {
+	ips []string
+	err error
+}
+
+func (r *testResolver) LookupIPAddr(_ context.Context, _ string) ([]net.IPAddr, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	var addrs []net.IPAddr
+	for _, ip := range r.ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+//nodeFindings returns findings about nodes
+func nodeFindings(findings []Finding) []Finding {
+	var result []Finding
+	for _, finding := range findings {
+		if finding.Code == FindingNodeCertMismatch || finding.Code == FindingNodeUnreachable {
+			result = append(result, finding)
+		}
+	}
+	return result
+}
+
+func TestChecker_Check_nodes(t *testing.T) {
+	leaf, intermediate, _ := newTestChain(t, "nodes.example.com")
+	otherLeaf, otherIntermediate, _ := newTestChain(t, "nodes.example.com")
+
+	address := startTestTLSServer(t, newTestTLSConfig(leaf, intermediate))
+	_, port, _ := net.SplitHostPort(address)
+	startTestTLSServerAt(t, newTestTLSConfig(otherLeaf, otherIntermediate), net.JoinHostPort("127.0.0.2", port))
+	startTestTLSServerAt(t, newTestTLSConfig(leaf, intermediate), net.JoinHostPort("127.0.0.3", port))
+	URL := net.JoinHostPort("nodes.example.com", port)
+
+	tests := []struct {
+		name              string
+		resolver          *testResolver
+		wantErrorCategory ErrorCategory
+		wantIP            string
+		wantNodes         []string
+		wantFindings      []FindingCode
+		wantFindingIP     string
+	}{
+		{
+			name:      "test single node",
+			resolver:  &testResolver{ips: []string{"127.0.0.1"}},
+			wantIP:    "127.0.0.1",
+			wantNodes: []string{"127.0.0.1"},
+		},
+		{
+			name:      "test nodes with same certificate",
+			resolver:  &testResolver{ips: []string{"127.0.0.1", "127.0.0.3"}},
+			wantIP:    "127.0.0.1",
+			wantNodes: []string{"127.0.0.1", "127.0.0.3"},
+		},
+		{
+			name:          "test node with different certificate",
+			resolver:      &testResolver{ips: []string{"127.0.0.2", "127.0.0.1", "127.0.0.3"}},
+			wantIP:        "127.0.0.1",
+			wantNodes:     []string{"127.0.0.2", "127.0.0.1", "127.0.0.3"},
+			wantFindings:  []FindingCode{FindingNodeCertMismatch},
+			wantFindingIP: "127.0.0.2",
+		},
+		{
+			name:          "test unreachable node",
+			resolver:      &testResolver{ips: []string{"127.0.0.4", "127.0.0.1"}},
+			wantIP:        "127.0.0.1",
+			wantNodes:     []string{"127.0.0.4", "127.0.0.1"},
+			wantFindings:  []FindingCode{FindingNodeUnreachable},
+			wantFindingIP: "127.0.0.4",
+		},
+		{
+			name:              "test all nodes unreachable",
+			resolver:          &testResolver{ips: []string{"127.0.0.4", "127.0.0.5"}},
+			wantErrorCategory: ErrorCategoryConnect,
+			wantNodes:         []string{"127.0.0.4", "127.0.0.5"},
+		},
+		{
+			name:              "test resolver error",
+			resolver:          &testResolver{err: &net.DNSError{Err: "no such host", Name: "nodes.example.com", IsNotFound: true}},
+			wantErrorCategory: ErrorCategoryDNS,
+		},
+		{
+			name:              "test resolver without addresses",
+			resolver:          &testResolver{},
+			wantErrorCategory: ErrorCategoryDNS,
+		},
+		{
+			name:              "test resolver unknown error",
+			resolver:          &testResolver{err: errors.New("resolver is broken")},
+			wantErrorCategory: ErrorCategoryDNS,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := &Checker{Resolver: tt.resolver}
+			got := checker.Check(context.Background(), URL)
+			if got.ErrorCategory != tt.wantErrorCategory {
+				t.Errorf("Check() error category = %v, want %v (error %v)", got.ErrorCategory, tt.wantErrorCategory, got.Err)
+			}
+			if got.IP != tt.wantIP {
+				t.Errorf("Check() IP = %v, want %v", got.IP, tt.wantIP)
+			}
+			var nodes []string
+			for _, node := range got.Nodes {
+				nodes = append(nodes, node.IP)
+			}
+			if !reflect.DeepEqual(nodes, tt.wantNodes) {
+				t.Errorf("Check() nodes = %v, want %v", nodes, tt.wantNodes)
+			}
+			findings := nodeFindings(got.Findings)
+			if !reflect.DeepEqual(findingsCodes(findings), tt.wantFindings) {
+				t.Errorf("Check() node findings = %v, want %v", findings, tt.wantFindings)
+			}
+			for _, finding := range findings {
+				if !strings.Contains(finding.Message, tt.wantFindingIP) {
+					t.Errorf("Check() finding %q does not contain IP %s", finding.Message, tt.wantFindingIP)
+				}
+			}
+		})
+	}
+}
+
+func TestChecker_Check_pinnedIP(t *testing.T) {
+	leaf, intermediate, _ := newTestChain(t, "shop.example.com")
+	address := startTestTLSServerAt(t, newTestTLSConfig(leaf, intermediate), "127.0.0.2:0")
+	_, port, _ := net.SplitHostPort(address)
+	//pinned IP must be dialed without resolving
+	checker := &Checker{Resolver: &testResolver{err: errors.New("resolver must not be used")}}
+
+	tests := []struct {
+		name              string
+		target            *Target
+		wantErrorCategory ErrorCategory
+		wantEndpoint      string
+		wantFindings      []FindingCode
+	}{
+		{
+			name:         "test pinned IP",
+			target:       &Target{Host: "shop.example.com", Port: port, IP: "127.0.0.2"},
+			wantEndpoint: address,
+			wantFindings: []FindingCode{FindingMissingIntermediate},
+		},
+		{
+			name:         "test pinned IP with other host",
+			target:       &Target{Host: "other.example.com", Port: port, IP: "127.0.0.2"},
+			wantEndpoint: address,
+			wantFindings: []FindingCode{FindingHostnameMismatch, FindingMissingIntermediate},
+		},
+		{
+			name:              "test pinned IP without server",
+			target:            &Target{Host: "shop.example.com", Port: port, IP: "127.0.0.3"},
+			wantErrorCategory: ErrorCategoryConnect,
+			wantEndpoint:      net.JoinHostPort("127.0.0.3", port),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, got := range []*CertReport{checker.CheckTarget(context.Background(), tt.target), checker.Check(context.Background(), tt.target.String())} {
+				if got.ErrorCategory != tt.wantErrorCategory {
+					t.Errorf("Check() error category = %v, want %v (error %v)", got.ErrorCategory, tt.wantErrorCategory, got.Err)
+				}
+				if got.Endpoint != tt.wantEndpoint {
+					t.Errorf("Check() endpoint = %v, want %v", got.Endpoint, tt.wantEndpoint)
+				}
+				if got.Err == nil && got.IP != tt.target.IP {
+					t.Errorf("Check() IP = %v, want %v", got.IP, tt.target.IP)
+				}
+				if !reflect.DeepEqual(findingsCodes(got.Findings), tt.wantFindings) {
+					t.Errorf("Check() findings = %v, want %v", got.Findings, tt.wantFindings)
+				}
+			}
+		})
+	}
+}
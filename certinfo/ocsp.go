@@ -0,0 +1,207 @@
+package certinfo
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ocsp"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+//OCSP findings
+const (
+	FindingRevoked     FindingCode = "revoked"
+	FindingOCSPUnknown FindingCode = "ocsp_unknown"
+	FindingOCSPError   FindingCode = "ocsp_error"
+	FindingOCSPStale   FindingCode = "ocsp_stale"
+)
+
+//DefaultHTTPTimeout - default timeout of HTTP requests to OCSP responders
+const DefaultHTTPTimeout = 10 * time.Second
+
+//maxOCSPResponseSize - max size of OCSP response downloaded from responder
+const maxOCSPResponseSize = 1 << 20
+
+//OCSP statuses of certificate
+const (
+	OCSPStatusGood    = "good"
+	OCSPStatusRevoked = "revoked"
+	OCSPStatusUnknown = "unknown"
+)
+
+//OCSP response sources
+const (
+	OCSPSourceStaple    = "staple"
+	OCSPSourceResponder = "responder"
+)
+
+//OCSPReport result of OCSP check of leaf certificate
+type OCSPReport struct {
+	//Stapled - server staples OCSP response
+	Stapled bool `json:"stapled"`
+	//Source - source of validated response: staple or responder. Empty if there is no valid response
+	Source       string    `json:"source,omitempty"`
+	ResponderURL string    `json:"responder_url,omitempty"`
+	Status       string    `json:"status,omitempty"`
+	ProducedAt   time.Time `json:"produced_at,omitempty"`
+	ThisUpdate   time.Time `json:"this_update,omitempty"`
+	NextUpdate   time.Time `json:"next_update,omitempty"`
+	RevokedAt    time.Time `json:"revoked_at,omitempty"`
+	//RevocationReason - reason code of revocation (RFC 5280, 5.3.1)
+	RevocationReason int `json:"revocation_reason,omitempty"`
+	//Raw - validated DER encoded OCSP response
+	Raw []byte `json:"-"`
+}
+
+//httpClient returns client of requests to OCSP responders, CRL distribution points and AIA URLs
+//If proxy is set, requests are sent through proxy like TLS connections to checked hosts
+func (c *Checker) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	client := &http.Client{Timeout: DefaultHTTPTimeout}
+	if c.Proxy != nil {
+		//connections are not reused, client is created for every request
+		client.Transport = &http.Transport{DialContext: c.proxyDialContext(c.Proxy), DisableKeepAlives: true}
+	}
+	return client
+}
+
+//CheckOCSP checks revocation status of leaf certificate
+//Stapled response is validated first, if there is no valid staple - OCSP responder from certificate AIA extension is queried
+//staple - OCSP response stapled by server, can be empty
+//Returns nil report if certificate has no OCSP responder and server does not staple response
+func (c *Checker) CheckOCSP(ctx context.Context, leaf, issuer *x509.Certificate, staple []byte, now time.Time) (*OCSPReport, []Finding) {
+	if len(staple) == 0 && len(leaf.OCSPServer) == 0 {
+		return nil, nil
+	}
+	if issuer == nil {
+		return nil, []Finding{{
+			Code:     FindingOCSPError,
+			Severity: SeverityWarning,
+			Message:  "cannot check OCSP status - issuer certificate is not served",
+		}}
+	}
+
+	report := &OCSPReport{Stapled: len(staple) > 0}
+	var findings []Finding
+	var response *ocsp.Response
+	if report.Stapled {
+		var err error
+		response, err = parseOCSPResponse(staple, leaf, issuer, now)
+		if err != nil {
+			findings = append(findings, Finding{
+				Code:     FindingOCSPError,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("stapled OCSP response is invalid - %v", err),
+			})
+		} else {
+			report.Source = OCSPSourceStaple
+			report.Raw = staple
+		}
+	}
+
+	if response == nil && len(leaf.OCSPServer) > 0 {
+		report.ResponderURL = leaf.OCSPServer[0]
+		raw, err := c.queryOCSP(ctx, report.ResponderURL, leaf, issuer)
+		if err == nil {
+			response, err = parseOCSPResponse(raw, leaf, issuer, now)
+		}
+		if err != nil {
+			log.Println(err)
+			findings = append(findings, Finding{
+				Code:     FindingOCSPError,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("cannot check OCSP status with responder %s - %v", report.ResponderURL, err),
+			})
+		} else {
+			report.Source = OCSPSourceResponder
+			report.Raw = raw
+		}
+	}
+	if response == nil {
+		return report, findings
+	}
+
+	report.ProducedAt = response.ProducedAt
+	report.ThisUpdate = response.ThisUpdate
+	report.NextUpdate = response.NextUpdate
+	switch response.Status {
+	case ocsp.Good:
+		report.Status = OCSPStatusGood
+	case ocsp.Revoked:
+		report.Status = OCSPStatusRevoked
+		report.RevokedAt = response.RevokedAt
+		report.RevocationReason = response.RevocationReason
+		findings = append(findings, Finding{
+			Code:     FindingRevoked,
+			Severity: SeverityCritical,
+			Message: fmt.Sprintf("leaf certificate %s is revoked at %s (OCSP %s, reason %d)",
+				leaf.Subject.CommonName, response.RevokedAt.Format("2006-01-02 15:04:05"), report.Source, response.RevocationReason),
+		})
+	default:
+		report.Status = OCSPStatusUnknown
+		findings = append(findings, Finding{
+			Code:     FindingOCSPUnknown,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("OCSP %s returns unknown status of leaf certificate %s", report.Source, leaf.Subject.CommonName),
+		})
+	}
+	if !response.NextUpdate.IsZero() && now.After(response.NextUpdate) {
+		findings = append(findings, Finding{
+			Code:     FindingOCSPStale,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("OCSP %s response is stale, next update was at %s", report.Source, response.NextUpdate.Format("2006-01-02 15:04:05")),
+		})
+	}
+	return report, findings
+}
+
+//parseOCSPResponse parses OCSP response and verifies its signature and certificate serial number
+func parseOCSPResponse(raw []byte, leaf, issuer *x509.Certificate, now time.Time) (*ocsp.Response, error) {
+	response, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if now.Before(response.ThisUpdate.Add(-5 * time.Minute)) {
+		return nil, fmt.Errorf("response is not valid before %s", response.ThisUpdate.Format("2006-01-02 15:04:05"))
+	}
+	return response, nil
+}
+
+//queryOCSP sends OCSP request for leaf certificate to responder with POST method
+func (c *Checker) queryOCSP(ctx context.Context, responderURL string, leaf, issuer *x509.Certificate) ([]byte, error) {
+	request, err := ocsp.CreateRequest(leaf, issuer, &ocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		return nil, err
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, responderURL, bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/ocsp-request")
+	httpRequest.Header.Set("Accept", "application/ocsp-response")
+
+	response, err := c.httpClient().Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("responder returns %s", response.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(response.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, errors.New("responder returns empty response")
+	}
+	return raw, nil
+}
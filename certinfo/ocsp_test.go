@@ -0,0 +1,268 @@
+package certinfo
+
+import (
+	"context"
+	"crypto/x509"
+	"golang.org/x/crypto/ocsp"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+//testOCSPResponder local OCSP responder, responses are signed by issuer
+type testOCSPResponder struct {
+	mu         sync.Mutex
+	issuer     *testCert
+	status     int
+	nextUpdate time.Time
+	fail       bool
+	requests   int
+}
+
+func (r *testOCSPResponder) set(status int, nextUpdate time.Time, fail bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status, r.nextUpdate, r.fail = status, nextUpdate, fail
+}
+
+func (r *testOCSPResponder) getRequests() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests
+}
+
+//response creates OCSP response for certificate with serial number
+func (r *testOCSPResponder) response(t *testing.T, cert *x509.Certificate, status int, nextUpdate time.Time) []byte {
+	template := ocsp.Response{
+		Status:       status,
+		SerialNumber: cert.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Hour),
+		NextUpdate:   nextUpdate,
+	}
+	if status == ocsp.Revoked {
+		template.RevokedAt = time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+		template.RevocationReason = ocsp.KeyCompromise
+	}
+	raw, err := ocsp.CreateResponse(r.issuer.cert, r.issuer.cert, template, r.issuer.key)
+	if err != nil {
+		t.Fatalf("cannot create OCSP response: %v", err)
+	}
+	return raw
+}
+
+func startTestOCSPResponder(t *testing.T, responder *testOCSPResponder) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		responder.mu.Lock()
+		responder.requests++
+		status, nextUpdate, fail := responder.status, responder.nextUpdate, responder.fail
+		responder.mu.Unlock()
+
+		body, _ := io.ReadAll(req.Body)
+		request, err := ocsp.ParseRequest(body)
+		if err != nil || fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = w.Write(responder.response(t, &x509.Certificate{SerialNumber: request.SerialNumber}, status, nextUpdate))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestChecker_CheckOCSP(t *testing.T) {
+	_, intermediate, _ := newTestChain(t, "ocsp.example.com")
+	responder := &testOCSPResponder{issuer: intermediate}
+	server := startTestOCSPResponder(t, responder)
+	leaf := newTestCert(t, testCertOptions{
+		commonName:  "ocsp.example.com",
+		dnsNames:    []string{"ocsp.example.com"},
+		ocspServers: []string{server.URL},
+	}, intermediate)
+	noOCSPLeaf := newTestCert(t, testCertOptions{commonName: "ocsp.example.com", dnsNames: []string{"ocsp.example.com"}}, intermediate)
+	nextUpdate := time.Now().Add(24 * time.Hour)
+
+	tests := []struct {
+		name             string
+		leaf             *testCert
+		issuer           *testCert
+		staple           []byte
+		responderStatus  int
+		responderFail    bool
+		nextUpdate       time.Time
+		wantReport       bool
+		wantStatus       string
+		wantSource       string
+		wantRequests     int
+		wantFindingCodes []FindingCode
+	}{
+		{
+			name:         "test good staple",
+			leaf:         leaf,
+			issuer:       intermediate,
+			staple:       responder.response(t, leaf.cert, ocsp.Good, nextUpdate),
+			wantReport:   true,
+			wantStatus:   OCSPStatusGood,
+			wantSource:   OCSPSourceStaple,
+			wantRequests: 0,
+		},
+		{
+			name:             "test revoked staple",
+			leaf:             leaf,
+			issuer:           intermediate,
+			staple:           responder.response(t, leaf.cert, ocsp.Revoked, nextUpdate),
+			wantReport:       true,
+			wantStatus:       OCSPStatusRevoked,
+			wantSource:       OCSPSourceStaple,
+			wantFindingCodes: []FindingCode{FindingRevoked},
+		},
+		{
+			name:            "test good responder",
+			leaf:            leaf,
+			issuer:          intermediate,
+			responderStatus: ocsp.Good,
+			nextUpdate:      nextUpdate,
+			wantReport:      true,
+			wantStatus:      OCSPStatusGood,
+			wantSource:      OCSPSourceResponder,
+			wantRequests:    1,
+		},
+		{
+			name:             "test revoked responder",
+			leaf:             leaf,
+			issuer:           intermediate,
+			responderStatus:  ocsp.Revoked,
+			nextUpdate:       nextUpdate,
+			wantReport:       true,
+			wantStatus:       OCSPStatusRevoked,
+			wantSource:       OCSPSourceResponder,
+			wantRequests:     1,
+			wantFindingCodes: []FindingCode{FindingRevoked},
+		},
+		{
+			name:             "test unknown status",
+			leaf:             leaf,
+			issuer:           intermediate,
+			responderStatus:  ocsp.Unknown,
+			nextUpdate:       nextUpdate,
+			wantReport:       true,
+			wantStatus:       OCSPStatusUnknown,
+			wantSource:       OCSPSourceResponder,
+			wantRequests:     1,
+			wantFindingCodes: []FindingCode{FindingOCSPUnknown},
+		},
+		{
+			name:             "test stale response",
+			leaf:             leaf,
+			issuer:           intermediate,
+			responderStatus:  ocsp.Good,
+			nextUpdate:       time.Now().Add(-time.Minute),
+			wantReport:       true,
+			wantStatus:       OCSPStatusGood,
+			wantSource:       OCSPSourceResponder,
+			wantRequests:     1,
+			wantFindingCodes: []FindingCode{FindingOCSPStale},
+		},
+		{
+			name:             "test responder error",
+			leaf:             leaf,
+			issuer:           intermediate,
+			responderFail:    true,
+			wantReport:       true,
+			wantRequests:     1,
+			wantFindingCodes: []FindingCode{FindingOCSPError},
+		},
+		{
+			name:             "test invalid staple",
+			leaf:             leaf,
+			issuer:           intermediate,
+			staple:           []byte("invalid"),
+			responderStatus:  ocsp.Good,
+			nextUpdate:       nextUpdate,
+			wantReport:       true,
+			wantStatus:       OCSPStatusGood,
+			wantSource:       OCSPSourceResponder,
+			wantRequests:     1,
+			wantFindingCodes: []FindingCode{FindingOCSPError},
+		},
+		{
+			name:             "test issuer is not served",
+			leaf:             leaf,
+			wantFindingCodes: []FindingCode{FindingOCSPError},
+		},
+		{
+			name:   "test no OCSP",
+			leaf:   noOCSPLeaf,
+			issuer: intermediate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responder.set(tt.responderStatus, tt.nextUpdate, tt.responderFail)
+			requests := responder.getRequests()
+			var issuer *x509.Certificate
+			if tt.issuer != nil {
+				issuer = tt.issuer.cert
+			}
+
+			checker := &Checker{HTTPClient: server.Client()}
+			report, findings := checker.CheckOCSP(context.Background(), tt.leaf.cert, issuer, tt.staple, time.Now())
+			if (report != nil) != tt.wantReport {
+				t.Fatalf("CheckOCSP() report = %v, want report %v", report, tt.wantReport)
+			}
+			if report != nil {
+				if report.Status != tt.wantStatus || report.Source != tt.wantSource || report.Stapled != (len(tt.staple) > 0) {
+					t.Errorf("CheckOCSP() status = %v, source = %v, stapled = %v, want %v, %v, %v",
+						report.Status, report.Source, report.Stapled, tt.wantStatus, tt.wantSource, len(tt.staple) > 0)
+				}
+			}
+			if got := responder.getRequests() - requests; got != tt.wantRequests {
+				t.Errorf("CheckOCSP() responder requests = %v, want %v", got, tt.wantRequests)
+			}
+			if got := findingsCodes(findings); !reflect.DeepEqual(got, tt.wantFindingCodes) {
+				t.Errorf("CheckOCSP() findings = %v, want %v", got, tt.wantFindingCodes)
+			}
+		})
+	}
+}
+
+func TestChecker_Check_OCSPStaple(t *testing.T) {
+	_, intermediate, _ := newTestChain(t, "ocsp.example.com")
+	responder := &testOCSPResponder{issuer: intermediate, status: ocsp.Good, nextUpdate: time.Now().Add(time.Hour)}
+	server := startTestOCSPResponder(t, responder)
+	leaf := newTestCert(t, testCertOptions{
+		commonName:  "ocsp.example.com",
+		dnsNames:    []string{"ocsp.example.com"},
+		ips:         []net.IP{net.ParseIP("127.0.0.1")},
+		ocspServers: []string{server.URL},
+	}, intermediate)
+
+	config := newTestTLSConfig(leaf, intermediate)
+	config.Certificates[0].OCSPStaple = responder.response(t, leaf.cert, ocsp.Revoked, time.Now().Add(time.Hour))
+	address := startTestTLSServer(t, config)
+
+	report := (&Checker{HTTPClient: server.Client()}).Check(context.Background(), address)
+	if report.Err != nil {
+		t.Fatalf("Check() error = %v", report.Err)
+	}
+	if report.OCSP == nil || !report.OCSP.Stapled || report.OCSP.Status != OCSPStatusRevoked {
+		t.Fatalf("Check() OCSP = %+v, want stapled revoked status", report.OCSP)
+	}
+	if responder.getRequests() != 0 {
+		t.Errorf("Check() responder is queried with valid staple")
+	}
+	found := false
+	for _, finding := range report.Findings {
+		if finding.Code == FindingRevoked && finding.Severity == SeverityCritical {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Check() findings = %v, want critical revoked finding", report.Findings)
+	}
+}
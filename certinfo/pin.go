@@ -0,0 +1,104 @@
+package certinfo
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+//SPKIPin returns SHA-256 hash of certificate public key (SubjectPublicKeyInfo) in base64, like pin-sha256 pins of mobile apps (RFC 7469)
+func SPKIPin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+//ParsePin parses SPKI SHA-256 pin in base64 or hex format, "sha256/" and "pin-sha256=" prefixes are allowed
+//Returns pin in base64 format
+func ParsePin(pin string) (string, error) {
+	pin = strings.TrimSpace(pin)
+	for _, prefix := range []string{"sha256/", "pin-sha256="} {
+		pin = strings.TrimPrefix(pin, prefix)
+	}
+	pin = strings.Trim(pin, `"`)
+
+	var hash []byte
+	var err error
+	if len(pin) == hex.EncodedLen(sha256.Size) {
+		hash, err = hex.DecodeString(pin)
+	} else {
+		hash, err = base64.StdEncoding.DecodeString(pin)
+	}
+	if err != nil || len(hash) != sha256.Size {
+		return "", errors.New("pin error - pin must be SHA-256 hash of public key in base64 or hex format")
+	}
+	return base64.StdEncoding.EncodeToString(hash), nil
+}
+
+//ChainPins returns SPKI pins of every certificate in chain
+func ChainPins(chain []CertDetails) []string {
+	var pins []string
+	for _, cert := range chain {
+		if cert.Certificate != nil {
+			pins = append(pins, SPKIPin(cert.Certificate))
+		}
+	}
+	return pins
+}
+
+//MatchPins returns true if chain contains public key of any pin
+func MatchPins(chain []CertDetails, pins []string) bool {
+	for _, chainPin := range ChainPins(chain) {
+		if stringInSlice(chainPin, pins) {
+			return true
+		}
+	}
+	return false
+}
+
+//TrustedRoots returns roots of chains verified with trusted roots, which are not served in chain
+//Returns nil if chain is not trusted or all roots are served
+func (c *Checker) TrustedRoots(chain []CertDetails, now time.Time) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for _, cert := range chain {
+		if cert.Certificate != nil {
+			certs = append(certs, cert.Certificate)
+		}
+	}
+	if len(certs) == 0 {
+		return nil
+	}
+	var roots []*x509.Certificate
+	for _, verified := range verifiedChains(certs, c.Roots, now) {
+		//root is skipped if it is served or found in other verified chain
+		root := verified[len(verified)-1]
+		known := false
+		for _, cert := range append(certs, roots...) {
+			if cert.Equal(root) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+//MatchVerifiedPins returns true if served chain or trusted root of verified chain contains public key of any pin
+//Pinned root matches, even if server does not send it
+func (c *Checker) MatchVerifiedPins(chain []CertDetails, pins []string, now time.Time) bool {
+	if MatchPins(chain, pins) {
+		return true
+	}
+	for _, root := range c.TrustedRoots(chain, now) {
+		if stringInSlice(SPKIPin(root), pins) {
+			return true
+		}
+	}
+	return false
+}
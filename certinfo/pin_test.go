@@ -0,0 +1,159 @@
+package certinfo
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestParsePin(t *testing.T) {
+	hash := sha256.Sum256([]byte("public key"))
+	pin := base64.StdEncoding.EncodeToString(hash[:])
+
+	tests := []struct {
+		name    string
+		pin     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "test base64 pin",
+			pin:  pin,
+			want: pin,
+		},
+		{
+			name: "test hex pin",
+			pin:  hex.EncodeToString(hash[:]),
+			want: pin,
+		},
+		{
+			name: "test OkHttp pin",
+			pin:  "sha256/" + pin,
+			want: pin,
+		},
+		{
+			name: "test HPKP pin",
+			pin:  `pin-sha256="` + pin + `"`,
+			want: pin,
+		},
+		{
+			name:    "test SHA-1 hash",
+			pin:     base64.StdEncoding.EncodeToString(hash[:20]),
+			wantErr: true,
+		},
+		{
+			name:    "test not encoded pin",
+			pin:     "example.com",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePin(tt.pin)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchPins(t *testing.T) {
+	leaf, intermediate, root := newTestChain(t, "example.com")
+	chain := []CertDetails{newCertDetails(0, leaf.cert), newCertDetails(1, intermediate.cert)}
+
+	tests := []struct {
+		name string
+		pins []string
+		want bool
+	}{
+		{
+			name: "test leaf pin",
+			pins: []string{SPKIPin(leaf.cert)},
+			want: true,
+		},
+		{
+			name: "test intermediate and backup pins",
+			pins: []string{SPKIPin(root.cert), SPKIPin(intermediate.cert)},
+			want: true,
+		},
+		{
+			name: "test pin of not served certificate",
+			pins: []string{SPKIPin(root.cert)},
+			want: false,
+		},
+		{
+			name: "test no pins",
+			pins: nil,
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchPins(chain, tt.pins); got != tt.want {
+				t.Errorf("MatchPins() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChecker_MatchVerifiedPins(t *testing.T) {
+	leaf, intermediate, root := newTestChain(t, "example.com")
+	_, otherIntermediate, otherRoot := newTestChain(t, "example.org")
+	chain := []CertDetails{newCertDetails(0, leaf.cert), newCertDetails(1, intermediate.cert)}
+	pool, err := NewRootPool([]*x509.Certificate{root.cert, otherRoot.cert}, true)
+	if err != nil {
+		t.Fatalf("NewRootPool() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		checker   *Checker
+		pins      []string
+		want      bool
+		wantRoots int
+	}{
+		{
+			name:      "test pin of served certificate",
+			checker:   (&Checker{}).WithRoots(pool),
+			pins:      []string{SPKIPin(intermediate.cert)},
+			want:      true,
+			wantRoots: 1,
+		},
+		{
+			name:      "test pin of trusted root, which is not served",
+			checker:   (&Checker{}).WithRoots(pool),
+			pins:      []string{SPKIPin(root.cert)},
+			want:      true,
+			wantRoots: 1,
+		},
+		{
+			name:      "test pin of root of other chain",
+			checker:   (&Checker{}).WithRoots(pool),
+			pins:      []string{SPKIPin(otherRoot.cert), SPKIPin(otherIntermediate.cert)},
+			want:      false,
+			wantRoots: 1,
+		},
+		{
+			name:    "test pin of root, which is not trusted",
+			checker: &Checker{},
+			pins:    []string{SPKIPin(root.cert)},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.checker.MatchVerifiedPins(chain, tt.pins, time.Now()); got != tt.want {
+				t.Errorf("MatchVerifiedPins() = %v, want %v", got, tt.want)
+			}
+			if got := tt.checker.TrustedRoots(chain, time.Now()); len(got) != tt.wantRoots {
+				t.Errorf("TrustedRoots() = %d roots, want %d", len(got), tt.wantRoots)
+			}
+		})
+	}
+}
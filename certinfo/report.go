@@ -0,0 +1,181 @@
+package certinfo
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//ErrorCategory category of error while target check
+type ErrorCategory string
+
+const (
+	ErrorCategoryNone      ErrorCategory = ""
+	ErrorCategoryTarget    ErrorCategory = "target"
+	ErrorCategoryDNS       ErrorCategory = "dns"
+	ErrorCategoryConnect   ErrorCategory = "connect"
+	ErrorCategoryProxy     ErrorCategory = "proxy"
+	ErrorCategoryTimeout   ErrorCategory = "timeout"
+	ErrorCategoryCanceled  ErrorCategory = "canceled"
+	ErrorCategorySTARTTLS  ErrorCategory = "starttls"
+	ErrorCategoryHandshake ErrorCategory = "handshake"
+	ErrorCategoryFile      ErrorCategory = "file"
+)
+
+//CertDetails fields of certificate from served chain
+type CertDetails struct {
+	//Position - position in served chain, 0 - leaf certificate
+	Position           int       `json:"position"`
+	Subject            string    `json:"subject"`
+	CommonName         string    `json:"common_name"`
+	DNSNames           []string  `json:"dns_names,omitempty"`
+	IPAddresses        []string  `json:"ip_addresses,omitempty"`
+	Issuer             string    `json:"issuer"`
+	IssuerCommonName   string    `json:"issuer_common_name"`
+	SerialNumber       string    `json:"serial_number"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	IsCA               bool      `json:"is_ca"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	PublicKeyAlgorithm string    `json:"public_key_algorithm"`
+	//KeySize - size of public key in bits
+	KeySize int `json:"key_size,omitempty"`
+	//KeyCurve - curve name of ECDSA public key
+	KeyCurve          string `json:"key_curve,omitempty"`
+	SHA256Fingerprint string `json:"sha256_fingerprint"`
+
+	Certificate *x509.Certificate `json:"-"`
+}
+
+//PositionName returns name of certificate position in served chain: leaf, intermediate or root certificate
+//Self-issued CA certificate is named root certificate
+func (d CertDetails) PositionName() string {
+	switch {
+	case d.Position == 0:
+		return "leaf certificate"
+	case d.Subject == d.Issuer:
+		return fmt.Sprintf("root certificate #%d", d.Position)
+	default:
+		return fmt.Sprintf("intermediate certificate #%d", d.Position)
+	}
+}
+
+//CertReport result of target check
+type CertReport struct {
+	//Target - canonical target view, or target as is if it cannot be parsed
+	Target string `json:"target"`
+	//Endpoint - dialed address in host:port format
+	Endpoint string `json:"endpoint,omitempty"`
+	//IP - checked IP address, or host name if host is resolved by proxy
+	IP          string        `json:"ip,omitempty"`
+	TLSVersion  string        `json:"tls_version,omitempty"`
+	CipherSuite string        `json:"cipher_suite,omitempty"`
+	Chain       []CertDetails `json:"chain,omitempty"`
+	Findings    []Finding     `json:"findings,omitempty"`
+	//FetchedChain - missing intermediate certificates fetched via AIA caIssuers URLs, empty if served chain is complete
+	FetchedChain []CertDetails `json:"fetched_chain,omitempty"`
+	//OCSP - OCSP status of leaf certificate, nil if certificate has no OCSP responder and response is not stapled
+	OCSP *OCSPReport `json:"ocsp,omitempty"`
+	//CRL - CRL status of leaf certificate, nil if certificate has no CRL distribution points or OCSP status is checked
+	CRL *CRLReport `json:"crl,omitempty"`
+	//SCT - Certificate Transparency check of leaf certificate, nil if log list is not configured
+	SCT *SCTReport `json:"sct,omitempty"`
+	//CAA - CAA check of target host, nil if CAA resolver is not set, host is IP address or CAA records cannot be resolved
+	CAA *CAAReport `json:"caa,omitempty"`
+	//TLSA - DANE check of target endpoint, nil if TLSA resolver is not set, host is IP address or endpoint has no TLSA records
+	TLSA *TLSAReport `json:"tlsa,omitempty"`
+	//Nodes - reports of every resolved IP address, top level fields are filled from node with most common certificate
+	Nodes     []NodeReport  `json:"nodes,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+
+	ErrorCategory ErrorCategory `json:"error_category,omitempty"`
+	ErrorMessage  string        `json:"error,omitempty"`
+	Err           error         `json:"-"`
+}
+
+//Certificates returns served chain certificates
+func (r *CertReport) Certificates() []*x509.Certificate {
+	var certs []*x509.Certificate
+	for _, details := range r.Chain {
+		certs = append(certs, details.Certificate)
+	}
+	return certs
+}
+
+//Leaf returns leaf certificate details, nil if chain is empty
+func (r *CertReport) Leaf() *CertDetails {
+	if len(r.Chain) == 0 {
+		return nil
+	}
+	return &r.Chain[0]
+}
+
+//setError sets report error with category
+func (r *CertReport) setError(category ErrorCategory, err error) {
+	r.ErrorCategory = category
+	r.ErrorMessage = err.Error()
+	r.Err = err
+}
+
+//checkError error of check stage with category
+type checkError struct {
+	category ErrorCategory
+	err      error
+}
+
+func (e *checkError) Error() string {
+	return e.err.Error()
+}
+
+func (e *checkError) Unwrap() error {
+	return e.err
+}
+
+//setCheckError sets report error, category is taken from *checkError
+func (r *CertReport) setCheckError(err error) {
+	r.setError(checkErrorCategory(err))
+}
+
+//checkErrorCategory returns category and unwrapped error of *checkError
+//Errors of other types are categorized as connect errors
+func checkErrorCategory(err error) (ErrorCategory, error) {
+	var checkErr *checkError
+	if errors.As(err, &checkErr) {
+		return checkErr.category, checkErr.err
+	}
+	return ErrorCategoryConnect, err
+}
+
+//newCertDetails creates certificate details
+func newCertDetails(position int, cert *x509.Certificate) CertDetails {
+	var ips []string
+	for _, ip := range cert.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+	fingerprint := sha256.Sum256(cert.Raw)
+	_, keySize, keyCurve := publicKeyInfo(cert)
+	return CertDetails{
+		Position:           position,
+		Subject:            cert.Subject.String(),
+		CommonName:         cert.Subject.CommonName,
+		DNSNames:           cert.DNSNames,
+		IPAddresses:        ips,
+		Issuer:             cert.Issuer.String(),
+		IssuerCommonName:   cert.Issuer.CommonName,
+		SerialNumber:       strings.ToUpper(cert.SerialNumber.Text(16)),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		IsCA:               cert.IsCA,
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		PublicKeyAlgorithm: cert.PublicKeyAlgorithm.String(),
+		KeySize:            keySize,
+		KeyCurve:           keyCurve,
+		SHA256Fingerprint:  strings.ToUpper(hex.EncodeToString(fingerprint[:])),
+		Certificate:        cert,
+	}
+}
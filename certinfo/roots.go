@@ -0,0 +1,49 @@
+package certinfo
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+//ErrorRootsPrivateKey - CA bundle contains private key
+var ErrorRootsPrivateKey = errors.New("roots error - CA bundle contains private key, send certificates only")
+
+//ParseRoots parses CA bundle with trusted roots, for example roots of internal PKI
+//Supported formats: PEM, DER and PKCS#7. Every certificate must be CA certificate or self-signed certificate
+//Returns ErrorRootsPrivateKey if bundle contains private key, private key must not be stored with trusted roots
+func ParseRoots(data []byte) ([]*x509.Certificate, error) {
+	file, err := ParseCertFile(data, "")
+	if err != nil {
+		return nil, fmt.Errorf("roots error - cannot parse CA bundle (%v)", err)
+	}
+	if file.HasPrivateKey {
+		return nil, ErrorRootsPrivateKey
+	}
+	if len(file.Certificates) == 0 {
+		return nil, errors.New("roots error - CA bundle does not contain certificates")
+	}
+	for _, cert := range file.Certificates {
+		if !cert.IsCA && !isSelfSigned(cert) {
+			return nil, fmt.Errorf("roots error - certificate %s is neither CA nor self-signed certificate", cert.Subject)
+		}
+	}
+	return file.Certificates, nil
+}
+
+//NewRootPool returns pool of trusted roots for Checker.Roots
+//rootsOnly - only roots are trusted. If false - roots are trusted in addition to system roots
+func NewRootPool(roots []*x509.Certificate, rootsOnly bool) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !rootsOnly {
+		var err error
+		pool, err = x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("roots error - cannot load system roots (%v)", err)
+		}
+	}
+	for _, root := range roots {
+		pool.AddCert(root)
+	}
+	return pool, nil
+}
@@ -0,0 +1,147 @@
+package certinfo
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func TestParseRoots(t *testing.T) {
+	leaf, intermediate, root := newTestChain(t, "example.com")
+	selfSigned := newTestCert(t, testCertOptions{commonName: "internal.example.com", dnsNames: []string{"internal.example.com"}}, nil)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(root.key)
+	if err != nil {
+		t.Fatalf("cannot marshal key: %v", err)
+	}
+	certPEM := func(certs ...*testCert) []byte {
+		var data []byte
+		for _, cert := range certs {
+			data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.cert.Raw})...)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		wantRoots int
+		wantErr   bool
+	}{
+		{
+			name:      "test PEM bundle",
+			data:      certPEM(root, intermediate),
+			wantRoots: 2,
+		},
+		{
+			name:      "test DER root",
+			data:      root.cert.Raw,
+			wantRoots: 1,
+		},
+		{
+			name:      "test self-signed certificate",
+			data:      certPEM(selfSigned),
+			wantRoots: 1,
+		},
+		{
+			name:    "test leaf certificate",
+			data:    certPEM(root, leaf),
+			wantErr: true,
+		},
+		{
+			name:    "test bundle with private key",
+			data:    append(certPEM(root), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})...),
+			wantErr: true,
+		},
+		{
+			name:    "test not certificate",
+			data:    []byte("internal root"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRoots(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRoots() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.wantRoots {
+				t.Errorf("ParseRoots() roots = %d, want %d", len(got), tt.wantRoots)
+			}
+		})
+	}
+}
+
+func TestChecker_Roots(t *testing.T) {
+	leaf, intermediate, root := newTestChain(t, "example.com")
+	address := startTestTLSServer(t, newTestTLSConfig(leaf, intermediate))
+
+	tests := []struct {
+		name        string
+		roots       []*x509.Certificate
+		rootsOnly   bool
+		wantTrusted bool
+	}{
+		{
+			name:        "test system roots",
+			wantTrusted: false,
+		},
+		{
+			name:        "test system and custom roots",
+			roots:       []*x509.Certificate{root.cert},
+			wantTrusted: true,
+		},
+		{
+			name:        "test custom roots only",
+			roots:       []*x509.Certificate{root.cert},
+			rootsOnly:   true,
+			wantTrusted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := &Checker{}
+			if tt.roots != nil {
+				pool, err := NewRootPool(tt.roots, tt.rootsOnly)
+				if err != nil {
+					t.Fatalf("NewRootPool() error = %v", err)
+				}
+				checker = checker.WithRoots(pool)
+			}
+			report := checker.Check(context.Background(), "example.com@"+address)
+			if report.Err != nil {
+				t.Fatalf("Check() error = %v", report.Err)
+			}
+			if got := chainTrusted(report.Findings); got != tt.wantTrusted {
+				t.Errorf("Check() chain trusted = %v, want %v, findings %v", got, tt.wantTrusted, report.Findings)
+			}
+		})
+	}
+}
+
+func TestChecker_Roots_SCT(t *testing.T) {
+	leaf, intermediate, root := newTestChain(t, "example.com")
+	address := startTestTLSServer(t, newTestTLSConfig(leaf, intermediate))
+	logs, err := ParseCTLogList(newTestCTLogList(t, newTestCTLog(t, "Test log", "usable")))
+	if err != nil {
+		t.Fatalf("cannot parse log list: %v", err)
+	}
+	pool, err := NewRootPool([]*x509.Certificate{root.cert}, true)
+	if err != nil {
+		t.Fatalf("NewRootPool() error = %v", err)
+	}
+
+	//chain of private root is trusted with custom roots, but it is not publicly trusted and SCTs are not required
+	report := (&Checker{CTLogs: logs}).WithRoots(pool).Check(context.Background(), "example.com@"+address)
+	if report.Err != nil {
+		t.Fatalf("Check() error = %v", report.Err)
+	}
+	if !chainTrusted(report.Findings) {
+		t.Errorf("Check() chain is not trusted, findings %v", report.Findings)
+	}
+	for _, finding := range report.Findings {
+		if finding.Code == FindingInsufficientSCTs {
+			t.Errorf("Check() finding = %v, SCTs are not required for private PKI", finding)
+		}
+	}
+}
@@ -0,0 +1,488 @@
+package certinfo
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+	"golang.org/x/crypto/ocsp"
+	"os"
+	"time"
+)
+
+//SCT findings
+const (
+	FindingInsufficientSCTs FindingCode = "insufficient_scts"
+	FindingInvalidSCT       FindingCode = "invalid_sct"
+)
+
+//SCT sources
+const (
+	SCTSourceCertificate = "certificate"
+	SCTSourceTLS         = "tls"
+	SCTSourceOCSP        = "ocsp"
+)
+
+var (
+	//oidSCTList - extension of certificate with embedded SCTs (RFC 6962, 3.3)
+	oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+	//oidOCSPSCTList - extension of OCSP single response with SCTs (RFC 6962, 3.3)
+	oidOCSPSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 5}
+)
+
+//CTLog Certificate Transparency log from log list
+type CTLog struct {
+	Description string
+	Operator    string
+	//ID - SHA-256 hash of log public key
+	ID  [sha256.Size]byte
+	Key crypto.PublicKey
+	//Rejected - SCTs of log are not accepted
+	Rejected bool
+	//RetiredAt - time of log retirement, SCTs issued after it are not accepted. Zero if log is not retired
+	RetiredAt time.Time
+}
+
+//CTLogList list of known Certificate Transparency logs
+type CTLogList struct {
+	logs map[[sha256.Size]byte]*CTLog
+}
+
+//ctLogListJSON log list in Chrome log_list.json (v3) format
+type ctLogListJSON struct {
+	Operators []struct {
+		Name string `json:"name"`
+		Logs []struct {
+			Description string `json:"description"`
+			Key         []byte `json:"key"`
+			State       map[string]struct {
+				Timestamp time.Time `json:"timestamp"`
+			} `json:"state"`
+		} `json:"logs"`
+	} `json:"operators"`
+}
+
+//LoadCTLogList reads log list file in Chrome log_list.json (v3) format
+func LoadCTLogList(path string) (*CTLogList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ct log list error - cannot read log list file (%v)", err)
+	}
+	return ParseCTLogList(data)
+}
+
+//ParseCTLogList parses log list in Chrome log_list.json (v3) format
+func ParseCTLogList(data []byte) (*CTLogList, error) {
+	var list ctLogListJSON
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("ct log list error - cannot parse log list (%v)", err)
+	}
+
+	result := &CTLogList{logs: map[[sha256.Size]byte]*CTLog{}}
+	for _, operator := range list.Operators {
+		for _, logJSON := range operator.Logs {
+			key, err := x509.ParsePKIXPublicKey(logJSON.Key)
+			if err != nil {
+				return nil, fmt.Errorf("ct log list error - cannot parse key of log %s (%v)", logJSON.Description, err)
+			}
+			ctLog := &CTLog{
+				Description: logJSON.Description,
+				Operator:    operator.Name,
+				ID:          sha256.Sum256(logJSON.Key),
+				Key:         key,
+			}
+			if _, ok := logJSON.State["rejected"]; ok {
+				ctLog.Rejected = true
+			}
+			if retired, ok := logJSON.State["retired"]; ok {
+				ctLog.RetiredAt = retired.Timestamp
+			}
+			result.logs[ctLog.ID] = ctLog
+		}
+	}
+	if len(result.logs) == 0 {
+		return nil, errors.New("ct log list error - log list has no logs")
+	}
+	return result, nil
+}
+
+//Len returns count of logs in list
+func (l *CTLogList) Len() int {
+	return len(l.logs)
+}
+
+//SCTDetails fields of single signed certificate timestamp
+type SCTDetails struct {
+	Source string `json:"source"`
+	//LogID - base64 encoded log ID
+	LogID     string    `json:"log_id"`
+	Log       string    `json:"log,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Valid     bool      `json:"valid"`
+	Error     string    `json:"error,omitempty"`
+}
+
+//SCTReport result of Certificate Transparency check of leaf certificate
+type SCTReport struct {
+	//Found - count of found SCTs from all sources
+	Found int `json:"found"`
+	//Valid - count of SCTs with valid signature of known log
+	Valid int `json:"valid"`
+	//Logs - distinct logs of valid SCTs
+	Logs []string `json:"logs,omitempty"`
+	//Required - count of distinct logs of embedded SCTs, which is required by Chrome CT policy
+	Required int          `json:"required"`
+	SCTs     []SCTDetails `json:"scts,omitempty"`
+}
+
+//publiclyTrusted returns true if chain is verified with system roots, CT policy applies only to publicly trusted certificates
+//Checker.Roots are not used: chains of private PKI verified with custom roots are not required to have SCTs
+func publiclyTrusted(chain []*x509.Certificate, now time.Time) bool {
+	return len(chain) > 0 && chainVerifies(chain, nil, now)
+}
+
+//CheckSCTs verifies SCTs of leaf certificate from certificate extension, TLS extension and OCSP staple with CTLogs
+//tlsSCTs - SCTs from TLS extension, staple - OCSP response stapled by server, both can be empty
+//publiclyTrusted - leaf is issued by publicly trusted CA, findings about missing SCTs are reported only for such certificates
+//Returns nil report if CTLogs is not set
+func (c *Checker) CheckSCTs(leaf, issuer *x509.Certificate, tlsSCTs [][]byte, staple []byte, publiclyTrusted bool, now time.Time) (*SCTReport, []Finding) {
+	if c.CTLogs == nil {
+		return nil, nil
+	}
+
+	report := &SCTReport{Required: requiredEmbeddedSCTs(leaf)}
+	var findings []Finding
+	embeddedLogs := map[string]bool{}
+	deliveredLogs := map[string]bool{}
+	addSCTs := func(source string, scts [][]byte, err error) {
+		if err != nil {
+			findings = append(findings, Finding{
+				Code:     FindingInvalidSCT,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("SCT list from %s cannot be parsed - %v", source, err),
+			})
+			return
+		}
+		for _, raw := range scts {
+			details := c.verifySCT(raw, source, leaf, issuer, now)
+			report.Found++
+			report.SCTs = append(report.SCTs, details)
+			if !details.Valid {
+				if details.Log != "" {
+					findings = append(findings, Finding{
+						Code:     FindingInvalidSCT,
+						Severity: SeverityWarning,
+						Message:  fmt.Sprintf("SCT of log %s from %s is invalid - %s", details.Log, source, details.Error),
+					})
+				}
+				continue
+			}
+			report.Valid++
+			if !stringInSlice(details.Log, report.Logs) {
+				report.Logs = append(report.Logs, details.Log)
+			}
+			if source == SCTSourceCertificate {
+				embeddedLogs[details.Log] = true
+			} else {
+				deliveredLogs[details.Log] = true
+			}
+		}
+	}
+
+	scts, err := embeddedSCTs(leaf)
+	addSCTs(SCTSourceCertificate, scts, err)
+	addSCTs(SCTSourceTLS, tlsSCTs, nil)
+	if len(staple) > 0 {
+		scts, err = stapledSCTs(staple, leaf, issuer)
+		addSCTs(SCTSourceOCSP, scts, err)
+	}
+
+	//SCTs delivered with TLS extension or OCSP staple are required from 2 distinct logs
+	if publiclyTrusted && len(embeddedLogs) < report.Required && len(deliveredLogs) < 2 {
+		findings = append(findings, Finding{
+			Code:     FindingInsufficientSCTs,
+			Severity: SeverityCritical,
+			Message: fmt.Sprintf("certificate has valid SCTs from %d distinct logs, Chrome CT policy requires %d embedded SCTs or 2 SCTs delivered with TLS or OCSP",
+				len(report.Logs), report.Required),
+		})
+	}
+	return report, findings
+}
+
+//requiredEmbeddedSCTs returns count of distinct logs of embedded SCTs required by Chrome CT policy for certificate lifetime
+func requiredEmbeddedSCTs(leaf *x509.Certificate) int {
+	if leaf.NotAfter.Sub(leaf.NotBefore) <= 180*24*time.Hour {
+		return 2
+	}
+	return 3
+}
+
+//embeddedSCTs returns serialized SCTs from certificate extension
+func embeddedSCTs(leaf *x509.Certificate) ([][]byte, error) {
+	for _, ext := range leaf.Extensions {
+		if ext.Id.Equal(oidSCTList) {
+			return parseSCTListExtension(ext.Value)
+		}
+	}
+	return nil, nil
+}
+
+//stapledSCTs returns serialized SCTs from single response extension of OCSP staple
+//Invalid staple is reported by OCSP check, so it has no SCTs
+func stapledSCTs(staple []byte, leaf, issuer *x509.Certificate) ([][]byte, error) {
+	if issuer == nil {
+		return nil, nil
+	}
+	response, err := ocsp.ParseResponseForCert(staple, leaf, issuer)
+	if err != nil {
+		return nil, nil
+	}
+	for _, ext := range response.Extensions {
+		if ext.Id.Equal(oidOCSPSCTList) {
+			return parseSCTListExtension(ext.Value)
+		}
+	}
+	return nil, nil
+}
+
+//parseSCTListExtension parses SCT list wrapped into ASN.1 OCTET STRING
+func parseSCTListExtension(value []byte) ([][]byte, error) {
+	var list []byte
+	if rest, err := asn1.Unmarshal(value, &list); err != nil || len(rest) > 0 {
+		return nil, errors.New("extension is not OCTET STRING")
+	}
+	return parseSCTList(list)
+}
+
+//parseSCTList parses TLS encoded SignedCertificateTimestampList (RFC 6962, 3.3)
+func parseSCTList(raw []byte) ([][]byte, error) {
+	input := cryptobyte.String(raw)
+	var list cryptobyte.String
+	if !input.ReadUint16LengthPrefixed(&list) || !input.Empty() {
+		return nil, errors.New("malformed SCT list")
+	}
+	var scts [][]byte
+	for !list.Empty() {
+		var sct cryptobyte.String
+		if !list.ReadUint16LengthPrefixed(&sct) || sct.Empty() {
+			return nil, errors.New("malformed SCT list")
+		}
+		scts = append(scts, sct)
+	}
+	return scts, nil
+}
+
+//signedCertificateTimestamp SCT version 1 (RFC 6962, 3.2)
+type signedCertificateTimestamp struct {
+	logID      [sha256.Size]byte
+	timestamp  uint64
+	extensions []byte
+	hash       uint8
+	algorithm  uint8
+	signature  []byte
+}
+
+//parseSCT parses serialized SCT
+func parseSCT(raw []byte) (*signedCertificateTimestamp, error) {
+	input := cryptobyte.String(raw)
+	sct := &signedCertificateTimestamp{}
+	var version uint8
+	var logID []byte
+	var extensions, signature cryptobyte.String
+	if !input.ReadUint8(&version) {
+		return nil, errors.New("malformed SCT")
+	}
+	if version != 0 {
+		return nil, fmt.Errorf("unsupported SCT version %d", version)
+	}
+	if !input.ReadBytes(&logID, sha256.Size) || !input.ReadUint64(&sct.timestamp) || !input.ReadUint16LengthPrefixed(&extensions) ||
+		!input.ReadUint8(&sct.hash) || !input.ReadUint8(&sct.algorithm) || !input.ReadUint16LengthPrefixed(&signature) || !input.Empty() {
+		return nil, errors.New("malformed SCT")
+	}
+	copy(sct.logID[:], logID)
+	sct.extensions = extensions
+	sct.signature = signature
+	return sct, nil
+}
+
+//time returns SCT timestamp
+func (s *signedCertificateTimestamp) time() time.Time {
+	return time.Unix(0, int64(s.timestamp)*int64(time.Millisecond)).UTC()
+}
+
+//verifySCT verifies serialized SCT of leaf certificate with log from CTLogs
+//Embedded SCTs are signed over precertificate, other SCTs are signed over leaf certificate
+func (c *Checker) verifySCT(raw []byte, source string, leaf, issuer *x509.Certificate, now time.Time) SCTDetails {
+	details := SCTDetails{Source: source}
+	sct, err := parseSCT(raw)
+	if err != nil {
+		details.Error = err.Error()
+		return details
+	}
+	details.LogID = base64.StdEncoding.EncodeToString(sct.logID[:])
+	details.Timestamp = sct.time()
+
+	ctLog, ok := c.CTLogs.logs[sct.logID]
+	if !ok {
+		details.Error = "unknown log"
+		return details
+	}
+	details.Log = ctLog.Description
+
+	var signed []byte
+	if source == SCTSourceCertificate {
+		if issuer == nil {
+			details.Error = "issuer certificate is not served"
+			return details
+		}
+		signed, err = precertSignedData(sct, leaf, issuer)
+	} else {
+		signed, err = certSignedData(sct, leaf)
+	}
+	if err == nil {
+		err = verifySCTSignature(ctLog.Key, sct, signed)
+	}
+	switch {
+	case err != nil:
+		details.Error = err.Error()
+	case details.Timestamp.After(now):
+		details.Error = "timestamp is in the future"
+	case ctLog.Rejected:
+		details.Error = "log is rejected"
+	case !ctLog.RetiredAt.IsZero() && details.Timestamp.After(ctLog.RetiredAt):
+		details.Error = fmt.Sprintf("log is retired at %s", ctLog.RetiredAt.Format("2006-01-02"))
+	default:
+		details.Valid = true
+	}
+	return details
+}
+
+//certSignedData returns data signed by log for x509_entry (RFC 6962, 3.2)
+func certSignedData(sct *signedCertificateTimestamp, leaf *x509.Certificate) ([]byte, error) {
+	builder := cryptobyte.NewBuilder(nil)
+	addSCTSignedHeader(builder, sct, 0)
+	builder.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(leaf.Raw)
+	})
+	builder.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(sct.extensions)
+	})
+	return builder.Bytes()
+}
+
+//precertSignedData returns data signed by log for precert_entry (RFC 6962, 3.2)
+func precertSignedData(sct *signedCertificateTimestamp, leaf, issuer *x509.Certificate) ([]byte, error) {
+	tbs, err := precertTBS(leaf)
+	if err != nil {
+		return nil, err
+	}
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	builder := cryptobyte.NewBuilder(nil)
+	addSCTSignedHeader(builder, sct, 1)
+	builder.AddBytes(issuerKeyHash[:])
+	builder.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(tbs)
+	})
+	builder.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(sct.extensions)
+	})
+	return builder.Bytes()
+}
+
+//addSCTSignedHeader adds version, signature type, timestamp and entry type of signed data
+func addSCTSignedHeader(builder *cryptobyte.Builder, sct *signedCertificateTimestamp, entryType uint16) {
+	builder.AddUint8(0) //v1
+	builder.AddUint8(0) //certificate_timestamp
+	builder.AddUint64(sct.timestamp)
+	builder.AddUint16(entryType)
+}
+
+//precertTBS returns TBSCertificate of leaf without SCT list extension
+func precertTBS(leaf *x509.Certificate) ([]byte, error) {
+	extensionsTag := cryptobyte_asn1.Tag(3).Constructed().ContextSpecific()
+	input := cryptobyte.String(leaf.RawTBSCertificate)
+	var tbs cryptobyte.String
+	if !input.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("malformed TBS certificate")
+	}
+
+	builder := cryptobyte.NewBuilder(nil)
+	builder.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for !tbs.Empty() {
+			var element cryptobyte.String
+			var tag cryptobyte_asn1.Tag
+			if !tbs.ReadAnyASN1Element(&element, &tag) {
+				b.SetError(errors.New("malformed TBS certificate"))
+				return
+			}
+			if tag != extensionsTag {
+				b.AddBytes(element)
+				continue
+			}
+
+			var wrapper, extensions cryptobyte.String
+			if !element.ReadASN1(&wrapper, extensionsTag) || !wrapper.ReadASN1(&extensions, cryptobyte_asn1.SEQUENCE) {
+				b.SetError(errors.New("malformed TBS certificate extensions"))
+				return
+			}
+			var kept [][]byte
+			for !extensions.Empty() {
+				var extension, body cryptobyte.String
+				var id asn1.ObjectIdentifier
+				if !extensions.ReadASN1Element(&extension, cryptobyte_asn1.SEQUENCE) {
+					b.SetError(errors.New("malformed TBS certificate extension"))
+					return
+				}
+				element := extension
+				if !element.ReadASN1(&body, cryptobyte_asn1.SEQUENCE) || !body.ReadASN1ObjectIdentifier(&id) {
+					b.SetError(errors.New("malformed TBS certificate extension"))
+					return
+				}
+				if !id.Equal(oidSCTList) {
+					kept = append(kept, extension)
+				}
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			b.AddASN1(extensionsTag, func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					for _, extension := range kept {
+						b.AddBytes(extension)
+					}
+				})
+			})
+		}
+	})
+	return builder.Bytes()
+}
+
+//verifySCTSignature verifies SHA-256 ECDSA or RSA signature of SCT
+func verifySCTSignature(key crypto.PublicKey, sct *signedCertificateTimestamp, signed []byte) error {
+	//hash algorithm 4 - sha256 (RFC 5246, 7.4.1.4.1)
+	if sct.hash != 4 {
+		return fmt.Errorf("unsupported hash algorithm %d", sct.hash)
+	}
+	digest := sha256.Sum256(signed)
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		if sct.algorithm != 3 || !ecdsa.VerifyASN1(key, digest[:], sct.signature) {
+			return errors.New("invalid signature")
+		}
+	case *rsa.PublicKey:
+		if sct.algorithm != 1 || rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sct.signature) != nil {
+			return errors.New("invalid signature")
+		}
+	default:
+		return errors.New("unsupported log key type")
+	}
+	return nil
+}
@@ -0,0 +1,457 @@
+package certinfo
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"golang.org/x/crypto/cryptobyte"
+	"golang.org/x/crypto/ocsp"
+	"math/big"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//testCTLog Certificate Transparency log, which signs test SCTs
+type testCTLog struct {
+	description string
+	key         *ecdsa.PrivateKey
+	state       string
+}
+
+func newTestCTLog(t *testing.T, description string, state string) *testCTLog {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	return &testCTLog{description: description, key: key, state: state}
+}
+
+//newTestCTLogList creates log list in Chrome log_list.json format
+func newTestCTLogList(t *testing.T, logs ...*testCTLog) []byte {
+	type logJSON struct {
+		Description string                    `json:"description"`
+		Key         []byte                    `json:"key"`
+		State       map[string]map[string]any `json:"state"`
+	}
+	var logsJSON []logJSON
+	for _, log := range logs {
+		key, err := x509.MarshalPKIXPublicKey(&log.key.PublicKey)
+		if err != nil {
+			t.Fatalf("cannot marshal key: %v", err)
+		}
+		state := map[string]map[string]any{log.state: {"timestamp": time.Now().Add(-24 * time.Hour)}}
+		logsJSON = append(logsJSON, logJSON{Description: log.description, Key: key, State: state})
+	}
+	data, err := json.Marshal(map[string]any{
+		"version":   "1.0",
+		"operators": []map[string]any{{"name": "Test operator", "logs": logsJSON}},
+	})
+	if err != nil {
+		t.Fatalf("cannot marshal log list: %v", err)
+	}
+	return data
+}
+
+//sct creates serialized SCT signed over entry. entryType 0 - x509_entry, 1 - precert_entry
+func (l *testCTLog) sct(t *testing.T, entryType uint16, entry []byte, timestamp time.Time) []byte {
+	key, err := x509.MarshalPKIXPublicKey(&l.key.PublicKey)
+	if err != nil {
+		t.Fatalf("cannot marshal key: %v", err)
+	}
+	logID := sha256.Sum256(key)
+	milliseconds := uint64(timestamp.UnixNano() / int64(time.Millisecond))
+
+	signed := cryptobyte.NewBuilder(nil)
+	signed.AddUint8(0)
+	signed.AddUint8(0)
+	signed.AddUint64(milliseconds)
+	signed.AddUint16(entryType)
+	signed.AddBytes(entry)
+	signed.AddUint16(0)
+	digest := sha256.Sum256(signed.BytesOrPanic())
+	signature, err := ecdsa.SignASN1(rand.Reader, l.key, digest[:])
+	if err != nil {
+		t.Fatalf("cannot sign SCT: %v", err)
+	}
+
+	sct := cryptobyte.NewBuilder(nil)
+	sct.AddUint8(0)
+	sct.AddBytes(logID[:])
+	sct.AddUint64(milliseconds)
+	sct.AddUint16(0)
+	sct.AddUint8(4)
+	sct.AddUint8(3)
+	sct.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(signature)
+	})
+	return sct.BytesOrPanic()
+}
+
+//certSCT creates SCT delivered with TLS extension or OCSP staple
+func (l *testCTLog) certSCT(t *testing.T, leaf *x509.Certificate) []byte {
+	entry := cryptobyte.NewBuilder(nil)
+	entry.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(leaf.Raw)
+	})
+	return l.sct(t, 0, entry.BytesOrPanic(), time.Now().Add(-time.Hour))
+}
+
+//newTestSCTList creates SCT list extension value
+func newTestSCTList(t *testing.T, scts [][]byte) []byte {
+	list := cryptobyte.NewBuilder(nil)
+	list.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, sct := range scts {
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddBytes(sct)
+			})
+		}
+	})
+	value, err := asn1.Marshal(list.BytesOrPanic())
+	if err != nil {
+		t.Fatalf("cannot marshal SCT list: %v", err)
+	}
+	return value
+}
+
+//newSCTTestLeaf creates leaf certificate signed by issuer with SCTs of logs embedded
+func newSCTTestLeaf(t *testing.T, issuer *testCert, validity time.Duration, logs ...*testCTLog) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "sct.example.com"},
+		DNSNames:     []string{"sct.example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	create := func() *x509.Certificate {
+		der, err := x509.CreateCertificate(rand.Reader, template, issuer.cert, &key.PublicKey, issuer.key)
+		if err != nil {
+			t.Fatalf("cannot create certificate: %v", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("cannot parse certificate: %v", err)
+		}
+		return cert
+	}
+
+	if len(logs) > 0 {
+		precert := create()
+		issuerKeyHash := sha256.Sum256(issuer.cert.RawSubjectPublicKeyInfo)
+		entry := cryptobyte.NewBuilder(nil)
+		entry.AddBytes(issuerKeyHash[:])
+		entry.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(precert.RawTBSCertificate)
+		})
+		var scts [][]byte
+		for _, log := range logs {
+			scts = append(scts, log.sct(t, 1, entry.BytesOrPanic(), time.Now().Add(-time.Hour)))
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: oidSCTList, Value: newTestSCTList(t, scts)}}
+	}
+	return &testCert{cert: create(), key: key}
+}
+
+func TestParseCTLogList(t *testing.T) {
+	log := newTestCTLog(t, "Test log", "usable")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantLen int
+		wantErr bool
+	}{
+		{
+			name:    "test valid log list",
+			data:    newTestCTLogList(t, log, newTestCTLog(t, "Test log 2", "retired")),
+			wantLen: 2,
+		},
+		{
+			name:    "test invalid JSON",
+			data:    []byte("{"),
+			wantErr: true,
+		},
+		{
+			name:    "test invalid log key",
+			data:    []byte(`{"operators":[{"name":"Test","logs":[{"description":"Test log","key":"AAAA"}]}]}`),
+			wantErr: true,
+		},
+		{
+			name:    "test empty log list",
+			data:    []byte(`{"operators":[]}`),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCTLogList(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCTLogList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Len() != tt.wantLen {
+				t.Errorf("ParseCTLogList() logs = %v, want %v", got.Len(), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestChecker_CheckSCTs(t *testing.T) {
+	_, intermediate, _ := newTestChain(t, "sct.example.com")
+	log1 := newTestCTLog(t, "Test log 1", "usable")
+	log2 := newTestCTLog(t, "Test log 2", "usable")
+	log3 := newTestCTLog(t, "Test log 3", "usable")
+	rejected := newTestCTLog(t, "Test rejected log", "rejected")
+	unknown := newTestCTLog(t, "Test unknown log", "usable")
+	logs, err := ParseCTLogList(newTestCTLogList(t, log1, log2, log3, rejected))
+	if err != nil {
+		t.Fatalf("cannot parse log list: %v", err)
+	}
+
+	day := 24 * time.Hour
+	twoSCTsLeaf := newSCTTestLeaf(t, intermediate, 90*day, log1, log2)
+	longLeaf := newSCTTestLeaf(t, intermediate, 365*day, log1, log2)
+	oneSCTLeaf := newSCTTestLeaf(t, intermediate, 90*day, log1)
+	rejectedLeaf := newSCTTestLeaf(t, intermediate, 90*day, log1, rejected)
+	unknownLeaf := newSCTTestLeaf(t, intermediate, 90*day, log1, unknown)
+	noSCTLeaf := newSCTTestLeaf(t, intermediate, 90*day)
+	staple, err := ocsp.CreateResponse(intermediate.cert, intermediate.cert, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: noSCTLeaf.cert.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Hour),
+		NextUpdate:   time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{
+			Id:    oidOCSPSCTList,
+			Value: newTestSCTList(t, [][]byte{log1.certSCT(t, noSCTLeaf.cert), log3.certSCT(t, noSCTLeaf.cert)}),
+		}},
+	}, intermediate.key)
+	if err != nil {
+		t.Fatalf("cannot create OCSP response: %v", err)
+	}
+
+	tests := []struct {
+		name             string
+		logs             *CTLogList
+		leaf             *testCert
+		issuer           *testCert
+		tlsSCTs          [][]byte
+		staple           []byte
+		publiclyTrusted  bool
+		wantReport       bool
+		wantFound        int
+		wantValid        int
+		wantLogs         []string
+		wantFindingCodes []FindingCode
+	}{
+		{
+			name:            "test embedded SCTs",
+			logs:            logs,
+			leaf:            twoSCTsLeaf,
+			issuer:          intermediate,
+			publiclyTrusted: true,
+			wantReport:      true,
+			wantFound:       2,
+			wantValid:       2,
+			wantLogs:        []string{"Test log 1", "Test log 2"},
+		},
+		{
+			name:             "test not enough embedded SCTs for long validity",
+			logs:             logs,
+			leaf:             longLeaf,
+			issuer:           intermediate,
+			publiclyTrusted:  true,
+			wantReport:       true,
+			wantFound:        2,
+			wantValid:        2,
+			wantLogs:         []string{"Test log 1", "Test log 2"},
+			wantFindingCodes: []FindingCode{FindingInsufficientSCTs},
+		},
+		{
+			name:             "test single embedded SCT",
+			logs:             logs,
+			leaf:             oneSCTLeaf,
+			issuer:           intermediate,
+			publiclyTrusted:  true,
+			wantReport:       true,
+			wantFound:        1,
+			wantValid:        1,
+			wantLogs:         []string{"Test log 1"},
+			wantFindingCodes: []FindingCode{FindingInsufficientSCTs},
+		},
+		{
+			name:       "test single embedded SCT of private CA",
+			logs:       logs,
+			leaf:       oneSCTLeaf,
+			issuer:     intermediate,
+			wantReport: true,
+			wantFound:  1,
+			wantValid:  1,
+			wantLogs:   []string{"Test log 1"},
+		},
+		{
+			name:            "test SCTs in TLS extension",
+			logs:            logs,
+			leaf:            oneSCTLeaf,
+			issuer:          intermediate,
+			tlsSCTs:         [][]byte{log2.certSCT(t, oneSCTLeaf.cert), log3.certSCT(t, oneSCTLeaf.cert)},
+			publiclyTrusted: true,
+			wantReport:      true,
+			wantFound:       3,
+			wantValid:       3,
+			wantLogs:        []string{"Test log 1", "Test log 2", "Test log 3"},
+		},
+		{
+			name:            "test SCTs in OCSP staple",
+			logs:            logs,
+			leaf:            noSCTLeaf,
+			issuer:          intermediate,
+			staple:          staple,
+			publiclyTrusted: true,
+			wantReport:      true,
+			wantFound:       2,
+			wantValid:       2,
+			wantLogs:        []string{"Test log 1", "Test log 3"},
+		},
+		{
+			name:             "test SCT of other certificate",
+			logs:             logs,
+			leaf:             noSCTLeaf,
+			issuer:           intermediate,
+			tlsSCTs:          [][]byte{log1.certSCT(t, oneSCTLeaf.cert)},
+			publiclyTrusted:  true,
+			wantReport:       true,
+			wantFound:        1,
+			wantFindingCodes: []FindingCode{FindingInvalidSCT, FindingInsufficientSCTs},
+		},
+		{
+			name:             "test SCT of rejected log",
+			logs:             logs,
+			leaf:             rejectedLeaf,
+			issuer:           intermediate,
+			wantReport:       true,
+			wantFound:        2,
+			wantValid:        1,
+			wantLogs:         []string{"Test log 1"},
+			wantFindingCodes: []FindingCode{FindingInvalidSCT},
+		},
+		{
+			name:             "test SCT of unknown log",
+			logs:             logs,
+			leaf:             unknownLeaf,
+			issuer:           intermediate,
+			publiclyTrusted:  true,
+			wantReport:       true,
+			wantFound:        2,
+			wantValid:        1,
+			wantLogs:         []string{"Test log 1"},
+			wantFindingCodes: []FindingCode{FindingInsufficientSCTs},
+		},
+		{
+			name:             "test embedded SCTs without issuer",
+			logs:             logs,
+			leaf:             twoSCTsLeaf,
+			publiclyTrusted:  true,
+			wantReport:       true,
+			wantFound:        2,
+			wantFindingCodes: []FindingCode{FindingInvalidSCT, FindingInvalidSCT, FindingInsufficientSCTs},
+		},
+		{
+			name:       "test malformed SCT",
+			logs:       logs,
+			leaf:       noSCTLeaf,
+			issuer:     intermediate,
+			tlsSCTs:    [][]byte{[]byte("malformed")},
+			wantReport: true,
+			wantFound:  1,
+		},
+		{
+			name:   "test log list is not configured",
+			leaf:   twoSCTsLeaf,
+			issuer: intermediate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var issuer *x509.Certificate
+			if tt.issuer != nil {
+				issuer = tt.issuer.cert
+			}
+
+			checker := &Checker{CTLogs: tt.logs}
+			report, findings := checker.CheckSCTs(tt.leaf.cert, issuer, tt.tlsSCTs, tt.staple, tt.publiclyTrusted, time.Now())
+			if (report != nil) != tt.wantReport {
+				t.Fatalf("CheckSCTs() report = %v, want report %v", report, tt.wantReport)
+			}
+			if report != nil && (report.Found != tt.wantFound || report.Valid != tt.wantValid || !reflect.DeepEqual(report.Logs, tt.wantLogs)) {
+				t.Errorf("CheckSCTs() found = %v, valid = %v, logs = %v, want %v, %v, %v",
+					report.Found, report.Valid, report.Logs, tt.wantFound, tt.wantValid, tt.wantLogs)
+			}
+			if got := findingsCodes(findings); !reflect.DeepEqual(got, tt.wantFindingCodes) {
+				t.Errorf("CheckSCTs() findings = %v, want %v", got, tt.wantFindingCodes)
+			}
+		})
+	}
+}
+
+func TestPrecertTBS(t *testing.T) {
+	_, intermediate, _ := newTestChain(t, "sct.example.com")
+	log := newTestCTLog(t, "Test log", "usable")
+	leaf := newSCTTestLeaf(t, intermediate, 90*24*time.Hour, log)
+
+	tbs, err := precertTBS(leaf.cert)
+	if err != nil {
+		t.Fatalf("precertTBS() error = %v", err)
+	}
+	var value asn1.RawValue
+	if rest, err := asn1.Unmarshal(tbs, &value); err != nil || len(rest) > 0 {
+		t.Fatalf("precertTBS() returns invalid ASN.1: %v", err)
+	}
+	if len(tbs) >= len(leaf.cert.RawTBSCertificate) {
+		t.Errorf("precertTBS() length = %v, want less than %v", len(tbs), len(leaf.cert.RawTBSCertificate))
+	}
+}
+
+func TestChecker_Check_SCT(t *testing.T) {
+	_, intermediate, _ := newTestChain(t, "sct.example.com")
+	log1 := newTestCTLog(t, "Test log 1", "usable")
+	log2 := newTestCTLog(t, "Test log 2", "usable")
+	logs, err := ParseCTLogList(newTestCTLogList(t, log1, log2))
+	if err != nil {
+		t.Fatalf("cannot parse log list: %v", err)
+	}
+	leaf := newSCTTestLeaf(t, intermediate, 90*24*time.Hour, log1)
+
+	config := newTestTLSConfig(leaf, intermediate)
+	config.Certificates[0].SignedCertificateTimestamps = [][]byte{log2.certSCT(t, leaf.cert)}
+	address := startTestTLSServer(t, config)
+
+	checker := &Checker{CTLogs: logs}
+	report := checker.Check(context.Background(), address)
+	if report.Err != nil {
+		t.Fatalf("Check() error = %v", report.Err)
+	}
+	if report.SCT == nil || report.SCT.Found != 2 || report.SCT.Valid != 2 || len(report.SCT.Logs) != 2 {
+		t.Errorf("Check() SCT = %+v, want 2 valid SCTs from 2 logs", report.SCT)
+	}
+
+	audit := checker.Audit(context.Background(), address)
+	if audit.Err != nil {
+		t.Fatalf("Audit() error = %v", audit.Err)
+	}
+	if audit.SCT == nil || audit.SCT.Valid != 2 || len(audit.SCT.Logs) != 2 {
+		t.Errorf("Audit() SCT = %+v, want 2 valid SCTs from 2 logs", audit.SCT)
+	}
+}
@@ -0,0 +1,326 @@
+package certinfo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+)
+
+//Supported STARTTLS protocols
+const (
+	ProtocolSMTP     = "smtp"
+	ProtocolIMAP     = "imap"
+	ProtocolPOP3     = "pop3"
+	ProtocolFTP      = "ftp"
+	ProtocolXMPP     = "xmpp"
+	ProtocolLDAP     = "ldap"
+	ProtocolPostgres = "postgres"
+)
+
+//protocolPorts default ports of STARTTLS protocols
+var protocolPorts = map[string]string{
+	ProtocolSMTP:     "25",
+	ProtocolIMAP:     "143",
+	ProtocolPOP3:     "110",
+	ProtocolFTP:      "21",
+	ProtocolXMPP:     "5222",
+	ProtocolLDAP:     "389",
+	ProtocolPostgres: "5432",
+}
+
+//SupportedProtocols returns sorted list of supported STARTTLS protocols
+func SupportedProtocols() []string {
+	var protocols []string
+	for protocol := range protocolPorts {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	return protocols
+}
+
+//startTLS runs plaintext upgrade handshake of protocol on connection
+//After successful return connection is ready for TLS handshake
+func startTLS(conn net.Conn, protocol string, host string) error {
+	reader := bufio.NewReader(conn)
+	var err error
+	switch protocol {
+	case "":
+		return nil
+	case ProtocolSMTP:
+		err = startTLSSMTP(conn, reader)
+	case ProtocolIMAP:
+		err = startTLSIMAP(conn, reader)
+	case ProtocolPOP3:
+		err = startTLSPOP3(conn, reader)
+	case ProtocolFTP:
+		err = startTLSFTP(conn, reader)
+	case ProtocolXMPP:
+		err = startTLSXMPP(conn, reader, host)
+	case ProtocolLDAP:
+		err = startTLSLDAP(conn, reader)
+	case ProtocolPostgres:
+		err = startTLSPostgres(conn, reader)
+	default:
+		return fmt.Errorf("unsupported protocol %s", protocol)
+	}
+	if err != nil {
+		return fmt.Errorf("%s STARTTLS error - %w", protocol, err)
+	}
+	if reader.Buffered() > 0 {
+		return fmt.Errorf("%s STARTTLS error - unexpected data from server before TLS handshake", protocol)
+	}
+	return nil
+}
+
+//writeLine writes line with CRLF to connection
+func writeLine(conn net.Conn, line string) error {
+	_, err := io.WriteString(conn, line+"\r\n")
+	return err
+}
+
+//readLine reads line without CRLF from connection
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+//readReply reads single or multiline reply in SMTP and FTP format and checks reply code
+func readReply(reader *bufio.Reader, expectCode string) error {
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return err
+		}
+		if len(line) < 3 || line[:3] != expectCode {
+			return fmt.Errorf("unexpected server reply %q, expected code %s", line, expectCode)
+		}
+		//multiline reply has "-" after code, last line has " " or nothing
+		if len(line) == 3 || line[3] != '-' {
+			return nil
+		}
+	}
+}
+
+func startTLSSMTP(conn net.Conn, reader *bufio.Reader) error {
+	if err := readReply(reader, "220"); err != nil {
+		return err
+	}
+	if err := writeLine(conn, "EHLO certcheckerbot"); err != nil {
+		return err
+	}
+	if err := readReply(reader, "250"); err != nil {
+		return err
+	}
+	if err := writeLine(conn, "STARTTLS"); err != nil {
+		return err
+	}
+	return readReply(reader, "220")
+}
+
+func startTLSFTP(conn net.Conn, reader *bufio.Reader) error {
+	if err := readReply(reader, "220"); err != nil {
+		return err
+	}
+	if err := writeLine(conn, "AUTH TLS"); err != nil {
+		return err
+	}
+	return readReply(reader, "234")
+}
+
+func startTLSIMAP(conn net.Conn, reader *bufio.Reader) error {
+	line, err := readLine(reader)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "* OK") {
+		return fmt.Errorf("unexpected server greeting %q", line)
+	}
+	if err := writeLine(conn, "a001 STARTTLS"); err != nil {
+		return err
+	}
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return err
+		}
+		//skip untagged responses
+		if strings.HasPrefix(line, "* ") {
+			continue
+		}
+		if strings.HasPrefix(line, "a001 OK") {
+			return nil
+		}
+		return fmt.Errorf("unexpected server reply %q", line)
+	}
+}
+
+func startTLSPOP3(conn net.Conn, reader *bufio.Reader) error {
+	line, err := readLine(reader)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return fmt.Errorf("unexpected server greeting %q", line)
+	}
+	if err := writeLine(conn, "STLS"); err != nil {
+		return err
+	}
+	line, err = readLine(reader)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return fmt.Errorf("unexpected server reply %q", line)
+	}
+	return nil
+}
+
+//readUntil reads data from reader until one of the markers is found, returns found marker
+func readUntil(reader *bufio.Reader, markers ...string) (string, error) {
+	var data []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		data = append(data, b)
+		for _, marker := range markers {
+			if bytes.HasSuffix(data, []byte(marker)) {
+				return marker, nil
+			}
+		}
+		if len(data) > 64*1024 {
+			return "", errors.New("too long server reply")
+		}
+	}
+}
+
+func startTLSXMPP(conn net.Conn, reader *bufio.Reader, host string) error {
+	_, err := fmt.Fprintf(conn, "<?xml version='1.0'?><stream:stream to='%s' xmlns='jabber:client' "+
+		"xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>", host)
+	if err != nil {
+		return err
+	}
+	marker, err := readUntil(reader, "<starttls", "</stream:features>")
+	if err != nil {
+		return err
+	}
+	if marker != "<starttls" {
+		return errors.New("server does not support STARTTLS")
+	}
+	if _, err := readUntil(reader, "</stream:features>"); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(conn, "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>"); err != nil {
+		return err
+	}
+	marker, err = readUntil(reader, "<proceed", "<failure")
+	if err != nil {
+		return err
+	}
+	if marker != "<proceed" {
+		return errors.New("server rejected STARTTLS")
+	}
+	_, err = readUntil(reader, "/>", "</proceed>")
+	return err
+}
+
+//ldapStartTLSRequest LDAP ExtendedRequest with StartTLS OID 1.3.6.1.4.1.1466.20037 and message id 1
+var ldapStartTLSRequest = append([]byte{0x30, 0x1d, 0x02, 0x01, 0x01, 0x77, 0x18, 0x80, 0x16},
+	[]byte("1.3.6.1.4.1.1466.20037")...)
+
+//readBERElement reads single BER element from reader
+func readBERElement(reader *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	length := int(header[1])
+	if length&0x80 != 0 {
+		lengthBytes := make([]byte, length&0x7f)
+		if len(lengthBytes) == 0 || len(lengthBytes) > 4 {
+			return nil, errors.New("incorrect BER length")
+		}
+		if _, err := io.ReadFull(reader, lengthBytes); err != nil {
+			return nil, err
+		}
+		header = append(header, lengthBytes...)
+		length = 0
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > 64*1024 {
+		return nil, errors.New("too long server reply")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+	return append(header, body...), nil
+}
+
+func startTLSLDAP(conn net.Conn, reader *bufio.Reader) error {
+	if _, err := conn.Write(ldapStartTLSRequest); err != nil {
+		return err
+	}
+	data, err := readBERElement(reader)
+	if err != nil {
+		return err
+	}
+	var message asn1.RawValue
+	if _, err := asn1.Unmarshal(data, &message); err != nil {
+		return err
+	}
+	var messageID int
+	rest, err := asn1.Unmarshal(message.Bytes, &messageID)
+	if err != nil {
+		return err
+	}
+	var operation asn1.RawValue
+	if _, err := asn1.Unmarshal(rest, &operation); err != nil {
+		return err
+	}
+	//ExtendedResponse - [APPLICATION 24]
+	if operation.Class != asn1.ClassApplication || operation.Tag != 24 {
+		return fmt.Errorf("unexpected LDAP response operation %d", operation.Tag)
+	}
+	var resultCode asn1.Enumerated
+	if _, err := asn1.Unmarshal(operation.Bytes, &resultCode); err != nil {
+		return err
+	}
+	if resultCode != 0 {
+		return fmt.Errorf("server rejected StartTLS, LDAP result code %d", resultCode)
+	}
+	return nil
+}
+
+//postgresSSLRequestCode code of SSLRequest message
+const postgresSSLRequestCode = 80877103
+
+func startTLSPostgres(conn net.Conn, reader *bufio.Reader) error {
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], postgresSSLRequestCode)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+	answer, err := reader.ReadByte()
+	if err != nil {
+		return err
+	}
+	if answer != 'S' {
+		return errors.New("server does not support SSL")
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package certinfo
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+//startTestSTARTTLSServer starts local server, which runs plaintext upgrade handshake and then TLS handshake
+//returns server address
+func startTestSTARTTLSServer(t *testing.T, config *tls.Config, upgrade func(conn net.Conn, reader *bufio.Reader) error) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot start test server: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				if err := upgrade(conn, bufio.NewReader(conn)); err != nil {
+					return
+				}
+				_ = tls.Server(conn, config).Handshake()
+			}(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+//expectLine reads line from reader and compares it with expected line
+func expectLine(reader *bufio.Reader, expected string) error {
+	line, err := readLine(reader)
+	if err != nil {
+		return err
+	}
+	if line != expected {
+		return errors.New("unexpected line " + line)
+	}
+	return nil
+}
+
+func fakeSMTPServer(conn net.Conn, reader *bufio.Reader) error {
+	_, _ = io.WriteString(conn, "220 mx.example.com ESMTP\r\n")
+	if err := expectLine(reader, "EHLO certcheckerbot"); err != nil {
+		return err
+	}
+	_, _ = io.WriteString(conn, "250-mx.example.com\r\n250-SIZE 1000000\r\n250 STARTTLS\r\n")
+	if err := expectLine(reader, "STARTTLS"); err != nil {
+		return err
+	}
+	_, _ = io.WriteString(conn, "220 Ready to start TLS\r\n")
+	return nil
+}
+
+func fakeSMTPServerNoTLS(conn net.Conn, reader *bufio.Reader) error {
+	_, _ = io.WriteString(conn, "220 mx.example.com ESMTP\r\n")
+	if err := expectLine(reader, "EHLO certcheckerbot"); err != nil {
+		return err
+	}
+	_, _ = io.WriteString(conn, "250 mx.example.com\r\n")
+	if err := expectLine(reader, "STARTTLS"); err != nil {
+		return err
+	}
+	_, _ = io.WriteString(conn, "502 Command not implemented\r\n")
+	return errors.New("no STARTTLS")
+}
+
+func fakeIMAPServer(conn net.Conn, reader *bufio.Reader) error {
+	_, _ = io.WriteString(conn, "* OK [CAPABILITY IMAP4rev1 STARTTLS] ready\r\n")
+	if err := expectLine(reader, "a001 STARTTLS"); err != nil {
+		return err
+	}
+	_, _ = io.WriteString(conn, "a001 OK Begin TLS negotiation now\r\n")
+	return nil
+}
+
+func fakePOP3Server(conn net.Conn, reader *bufio.Reader) error {
+	_, _ = io.WriteString(conn, "+OK POP3 ready\r\n")
+	if err := expectLine(reader, "STLS"); err != nil {
+		return err
+	}
+	_, _ = io.WriteString(conn, "+OK Begin TLS negotiation\r\n")
+	return nil
+}
+
+func fakeFTPServer(conn net.Conn, reader *bufio.Reader) error {
+	_, _ = io.WriteString(conn, "220-Welcome\r\n220 FTP ready\r\n")
+	if err := expectLine(reader, "AUTH TLS"); err != nil {
+		return err
+	}
+	_, _ = io.WriteString(conn, "234 AUTH TLS successful\r\n")
+	return nil
+}
+
+func fakeXMPPServer(conn net.Conn, reader *bufio.Reader) error {
+	if _, err := readUntil(reader, "version='1.0'>"); err != nil {
+		return err
+	}
+	_, _ = io.WriteString(conn, "<?xml version='1.0'?><stream:stream from='example.com' id='1' version='1.0' "+
+		"xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams'>"+
+		"<stream:features><starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'><required/></starttls></stream:features>")
+	if _, err := readUntil(reader, "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>"); err != nil {
+		return err
+	}
+	_, _ = io.WriteString(conn, "<proceed xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")
+	return nil
+}
+
+func fakeLDAPServer(conn net.Conn, reader *bufio.Reader) error {
+	request, err := readBERElement(reader)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(request), "1.3.6.1.4.1.1466.20037") {
+		return errors.New("not StartTLS request")
+	}
+	//ExtendedResponse with success result code
+	_, _ = conn.Write([]byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07, 0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00})
+	return nil
+}
+
+func fakePostgresServer(conn net.Conn, reader *bufio.Reader) error {
+	request := make([]byte, 8)
+	if _, err := io.ReadFull(reader, request); err != nil {
+		return err
+	}
+	if binary.BigEndian.Uint32(request[4:8]) != postgresSSLRequestCode {
+		return errors.New("not SSLRequest")
+	}
+	_, _ = conn.Write([]byte{'S'})
+	return nil
+}
+
+func fakePostgresServerNoSSL(conn net.Conn, reader *bufio.Reader) error {
+	request := make([]byte, 8)
+	if _, err := io.ReadFull(reader, request); err != nil {
+		return err
+	}
+	_, _ = conn.Write([]byte{'N'})
+	return errors.New("no SSL")
+}
+
+func TestCheck_STARTTLS(t *testing.T) {
+	leaf, intermediate, _ := newTestChain(t, "mx.example.com")
+	config := newTestTLSConfig(leaf, intermediate)
+
+	tests := []struct {
+		name       string
+		protocol   string
+		server     func(conn net.Conn, reader *bufio.Reader) error
+		wantErr    string
+		certsCount int
+	}{
+		{name: "test SMTP", protocol: ProtocolSMTP, server: fakeSMTPServer, certsCount: 1},
+		{name: "test IMAP", protocol: ProtocolIMAP, server: fakeIMAPServer, certsCount: 1},
+		{name: "test POP3", protocol: ProtocolPOP3, server: fakePOP3Server, certsCount: 1},
+		{name: "test FTP", protocol: ProtocolFTP, server: fakeFTPServer, certsCount: 1},
+		{name: "test XMPP", protocol: ProtocolXMPP, server: fakeXMPPServer, certsCount: 1},
+		{name: "test LDAP", protocol: ProtocolLDAP, server: fakeLDAPServer, certsCount: 1},
+		{name: "test PostgreSQL", protocol: ProtocolPostgres, server: fakePostgresServer, certsCount: 1},
+		{
+			name:     "test SMTP without STARTTLS",
+			protocol: ProtocolSMTP,
+			server:   fakeSMTPServerNoTLS,
+			wantErr:  "smtp STARTTLS error - unexpected server reply \"502 Command not implemented\", expected code 220",
+		},
+		{
+			name:     "test PostgreSQL without SSL",
+			protocol: ProtocolPostgres,
+			server:   fakePostgresServerNoSSL,
+			wantErr:  "postgres STARTTLS error - server does not support SSL",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := startTestSTARTTLSServer(t, config, tt.server)
+			URL := tt.protocol + "://" + address
+
+			got, gotCerts, err := checkAndFormat(URL, false)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Check() error = %v, want error %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Check() unexpected error: %v", err)
+				return
+			}
+			if len(gotCerts) != tt.certsCount {
+				t.Errorf("Check() incorrect certs count in array got %d, want %d", len(gotCerts), tt.certsCount)
+			}
+			want := "⚠️ Check certificate for domain: " + regexp.QuoteMeta(URL) + "\nDNSNames: \\[mx\\.example\\.com\\]\n"
+			res, err := regexp.MatchString(want, got)
+			if err != nil {
+				t.Errorf("Check() - regex error: %s", err)
+			}
+			if !res {
+				t.Errorf("Check() = %v, regex pattern = %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package certinfo
+
+import (
+	"fmt"
+	"golang.org/x/net/idna"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const defaultPort = "443"
+
+//maxHostNameLength - max length of host name in DNS (RFC 1035, 2.3.4)
+const maxHostNameLength = 253
+
+//maxHostLabelLength - max length of host name label in DNS (RFC 1035, 2.3.4)
+const maxHostLabelLength = 63
+
+//urlSchemePorts default ports of URL schemes with direct TLS connection, which are accepted in pasted URLs
+//http URLs are checked on 443 port, because served certificate of site is checked
+var urlSchemePorts = map[string]string{
+	"https": "443",
+	"http":  "443",
+	"wss":   "443",
+	"ldaps": "636",
+	"imaps": "993",
+	"pop3s": "995",
+	"smtps": "465",
+	"ftps":  "990",
+}
+
+//Target endpoint for certificate check
+type Target struct {
+	//Protocol - STARTTLS protocol, empty for direct TLS connection
+	Protocol string
+	//Host - host name, used as SNI and for certificate verification
+	Host string
+	Port string
+	//IP - IP address to connect instead of resolved host addresses, empty if host must be resolved
+	IP string
+}
+
+//ParseTarget parse target string to Target
+//Supported formats: host, host:port, IPv4, IPv4:port, IPv6, [IPv6], [IPv6]:port
+//Target can be prefixed with STARTTLS protocol, for example: smtp://mx.example.com:25
+//To connect to specific IP address instead of resolving host use host@IP format, for example: shop.example.com@10.0.0.5:443
+//If port is not specified - used default port of protocol, or 443 for direct TLS connection
+//Pasted URLs are accepted: scheme is used to infer port (https://example.com/path - 443, ldaps://example.com - 636),
+//credentials, path, query and fragment are dropped
+//Host name is returned in canonical form: lower case, IDN in punycode, without trailing dot
+func ParseTarget(target string) (*Target, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil, fmt.Errorf("target parse error - empty target")
+	}
+
+	input := target
+	protocol := ""
+	schemePort := defaultPort
+	isURL := false
+	if i := strings.Index(target, "://"); i != -1 {
+		scheme := strings.ToLower(target[:i])
+		target = target[i+3:]
+		if port, ok := urlSchemePorts[scheme]; ok {
+			schemePort = port
+			isURL = true
+		} else if _, ok := protocolPorts[scheme]; ok {
+			protocol = scheme
+			schemePort = protocolPorts[scheme]
+		} else {
+			return nil, fmt.Errorf("target parse error - unsupported protocol %s. Supported protocols: %s", scheme, strings.Join(SupportedProtocols(), ", "))
+		}
+	}
+	//path, query and fragment of pasted URL are not used for check
+	if i := strings.IndexAny(target, "/?#"); i != -1 {
+		target = target[:i]
+	}
+	if isURL {
+		target = stripURLCredentials(target)
+	}
+	if target == "" {
+		return nil, fmt.Errorf("target parse error - empty host in target %s", input)
+	}
+
+	ip := ""
+	if i := strings.Index(target, "@"); i != -1 {
+		host := target[:i]
+		if host == "" || strings.ContainsAny(host, ":[]") {
+			return nil, fmt.Errorf("target parse error - incorrect host %s in target %s, expected host@IP:port format", host, target)
+		}
+		h, port, err := splitTargetHostPort(target[i+1:], schemePort)
+		if err != nil {
+			return nil, err
+		}
+		parsedIP := net.ParseIP(h)
+		if parsedIP == nil {
+			return nil, fmt.Errorf("target parse error - incorrect IP address %s in target %s, expected host@IP:port format", h, target)
+		}
+		ip = parsedIP.String()
+		target = host
+		if port != schemePort {
+			target = net.JoinHostPort(host, port)
+		}
+	}
+
+	host, port, err := splitTargetHostPort(target, schemePort)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
+		return nil, fmt.Errorf("target parse error - empty host in target %s", target)
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil || portNumber < 1 || portNumber > 65535 {
+		return nil, fmt.Errorf("target parse error - incorrect port %s, port must be integer number in 1..65535 range", port)
+	}
+	host, err = normalizeHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Target{Protocol: protocol, Host: host, Port: strconv.Itoa(portNumber), IP: ip}, nil
+}
+
+//stripURLCredentials drops user:password@ part of pasted URL host
+//host@IP format is kept, if part before @ is host name
+func stripURLCredentials(target string) string {
+	i := strings.LastIndex(target, "@")
+	if i == -1 {
+		return target
+	}
+	userinfo, host := target[:i], target[i+1:]
+	if userinfo != "" && !strings.Contains(userinfo, ":") {
+		if h, _, err := splitTargetHostPort(host, defaultPort); err == nil && net.ParseIP(h) != nil {
+			return target
+		}
+	}
+	return host
+}
+
+//normalizeHost returns host name in canonical form: lower case, IDN in punycode, without trailing dot
+//Returns error if host is not valid host name. IP addresses are returned in canonical form, for example 2001:db8::1
+func normalizeHost(host string) (string, error) {
+	host = strings.TrimSuffix(host, ".")
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+	if strings.HasPrefix(host, "*.") {
+		return "", fmt.Errorf("target parse error - wildcard name %s cannot be checked, specify host name covered by wildcard certificate, for example www.%s", host, host[2:])
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("target parse error - invalid host name %s (%v)", host, err)
+	}
+	if len(ascii) > maxHostNameLength {
+		return "", fmt.Errorf("target parse error - invalid host name %s, host name must be not longer than %d characters", host, maxHostNameLength)
+	}
+	for _, label := range strings.Split(ascii, ".") {
+		if label == "" || len(label) > maxHostLabelLength {
+			return "", fmt.Errorf("target parse error - invalid host name %s, every label of host name must be 1..%d characters long", host, maxHostLabelLength)
+		}
+	}
+	return ascii, nil
+}
+
+//splitTargetHostPort splits target without protocol to host and port
+//If port is not specified - returns defaultPort
+func splitTargetHostPort(target string, defaultPort string) (host, port string, err error) {
+	host = target
+	port = defaultPort
+
+	switch {
+	case strings.HasPrefix(target, "["):
+		if strings.HasSuffix(target, "]") {
+			host = target[1 : len(target)-1]
+		} else {
+			h, p, err := net.SplitHostPort(target)
+			if err != nil {
+				return "", "", fmt.Errorf("target parse error - incorrect target %s (%v)", target, err)
+			}
+			host, port = h, p
+		}
+		if net.ParseIP(host) == nil {
+			return "", "", fmt.Errorf("target parse error - incorrect IPv6 address %s", host)
+		}
+	case strings.Count(target, ":") == 1:
+		h, p, err := net.SplitHostPort(target)
+		if err != nil {
+			return "", "", fmt.Errorf("target parse error - incorrect target %s (%v)", target, err)
+		}
+		host, port = h, p
+	case strings.Count(target, ":") > 1:
+		//IPv6 without brackets can't contain port
+		if net.ParseIP(target) == nil {
+			return "", "", fmt.Errorf("target parse error - incorrect IPv6 address %s. Use [IPv6]:port format to specify port", target)
+		}
+	}
+	return host, port, nil
+}
+
+//Address returns address for dial in host:port format, IP address is used as host if it is specified
+func (t *Target) Address() string {
+	if t.IP != "" {
+		return net.JoinHostPort(t.IP, t.Port)
+	}
+	return net.JoinHostPort(t.Host, t.Port)
+}
+
+//String returns canonical target view. Default port of protocol is omitted
+func (t *Target) String() string {
+	result := bracketIPv6(t.Host)
+	if t.IP != "" {
+		result += "@" + bracketIPv6(t.IP)
+	}
+	if t.Port != defaultTargetPort(t.Protocol) {
+		result += ":" + t.Port
+	}
+	if t.Protocol != "" {
+		result = t.Protocol + "://" + result
+	}
+	return result
+}
+
+//bracketIPv6 returns IPv6 address in brackets, other hosts are returned as is
+func bracketIPv6(host string) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+//defaultTargetPort returns default port for protocol
+func defaultTargetPort(protocol string) string {
+	if port, ok := protocolPorts[protocol]; ok {
+		return port
+	}
+	return defaultPort
+}
@@ -0,0 +1,307 @@
+package certinfo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		want        *Target
+		wantString  string
+		wantAddress string
+		wantErr     bool
+	}{
+		{
+			name:        "test host without port",
+			target:      "google.com",
+			want:        &Target{Host: "google.com", Port: "443"},
+			wantString:  "google.com",
+			wantAddress: "google.com:443",
+		},
+		{
+			name:        "test host with port",
+			target:      "ldap.example.com:636",
+			want:        &Target{Host: "ldap.example.com", Port: "636"},
+			wantString:  "ldap.example.com:636",
+			wantAddress: "ldap.example.com:636",
+		},
+		{
+			name:        "test host with default port",
+			target:      "google.com:443",
+			want:        &Target{Host: "google.com", Port: "443"},
+			wantString:  "google.com",
+			wantAddress: "google.com:443",
+		},
+		{
+			name:        "test IPv4 without port",
+			target:      "10.0.0.5",
+			want:        &Target{Host: "10.0.0.5", Port: "443"},
+			wantString:  "10.0.0.5",
+			wantAddress: "10.0.0.5:443",
+		},
+		{
+			name:        "test IPv4 with port",
+			target:      "10.0.0.5:8443",
+			want:        &Target{Host: "10.0.0.5", Port: "8443"},
+			wantString:  "10.0.0.5:8443",
+			wantAddress: "10.0.0.5:8443",
+		},
+		{
+			name:        "test bare IPv6",
+			target:      "2001:db8::1",
+			want:        &Target{Host: "2001:db8::1", Port: "443"},
+			wantString:  "[2001:db8::1]",
+			wantAddress: "[2001:db8::1]:443",
+		},
+		{
+			name:        "test bracketed IPv6 without port",
+			target:      "[2001:db8::1]",
+			want:        &Target{Host: "2001:db8::1", Port: "443"},
+			wantString:  "[2001:db8::1]",
+			wantAddress: "[2001:db8::1]:443",
+		},
+		{
+			name:        "test IPv6 in non-canonical form",
+			target:      "[2001:DB8:0:0::0001]:8443",
+			want:        &Target{Host: "2001:db8::1", Port: "8443"},
+			wantString:  "[2001:db8::1]:8443",
+			wantAddress: "[2001:db8::1]:8443",
+		},
+		{
+			name:        "test bracketed IPv6 with port",
+			target:      "[::1]:993",
+			want:        &Target{Host: "::1", Port: "993"},
+			wantString:  "[::1]:993",
+			wantAddress: "[::1]:993",
+		},
+		{
+			name:        "test STARTTLS protocol without port",
+			target:      "smtp://mx.example.com",
+			want:        &Target{Protocol: "smtp", Host: "mx.example.com", Port: "25"},
+			wantString:  "smtp://mx.example.com",
+			wantAddress: "mx.example.com:25",
+		},
+		{
+			name:        "test STARTTLS protocol with port",
+			target:      "SMTP://mx.example.com:587",
+			want:        &Target{Protocol: "smtp", Host: "mx.example.com", Port: "587"},
+			wantString:  "smtp://mx.example.com:587",
+			wantAddress: "mx.example.com:587",
+		},
+		{
+			name:        "test STARTTLS protocol with IPv6",
+			target:      "postgres://[::1]",
+			want:        &Target{Protocol: "postgres", Host: "::1", Port: "5432"},
+			wantString:  "postgres://[::1]",
+			wantAddress: "[::1]:5432",
+		},
+		{
+			name:        "test host with IP",
+			target:      "shop.example.com@10.0.0.5:443",
+			want:        &Target{Host: "shop.example.com", Port: "443", IP: "10.0.0.5"},
+			wantString:  "shop.example.com@10.0.0.5",
+			wantAddress: "10.0.0.5:443",
+		},
+		{
+			name:        "test host with IP without port",
+			target:      "shop.example.com@10.0.0.5",
+			want:        &Target{Host: "shop.example.com", Port: "443", IP: "10.0.0.5"},
+			wantString:  "shop.example.com@10.0.0.5",
+			wantAddress: "10.0.0.5:443",
+		},
+		{
+			name:        "test host with IPv6 and port",
+			target:      "shop.example.com@[2001:db8::0:5]:8443",
+			want:        &Target{Host: "shop.example.com", Port: "8443", IP: "2001:db8::5"},
+			wantString:  "shop.example.com@[2001:db8::5]:8443",
+			wantAddress: "[2001:db8::5]:8443",
+		},
+		{
+			name:        "test STARTTLS protocol with host and IP",
+			target:      "smtp://mx.example.com@10.0.0.7",
+			want:        &Target{Protocol: "smtp", Host: "mx.example.com", Port: "25", IP: "10.0.0.7"},
+			wantString:  "smtp://mx.example.com@10.0.0.7",
+			wantAddress: "10.0.0.7:25",
+		},
+		{
+			name:        "test https URL",
+			target:      "https://Example.com/path?x=1#top",
+			want:        &Target{Host: "example.com", Port: "443"},
+			wantString:  "example.com",
+			wantAddress: "example.com:443",
+		},
+		{
+			name:        "test http URL with port",
+			target:      "http://example.com:8443/",
+			want:        &Target{Host: "example.com", Port: "8443"},
+			wantString:  "example.com:8443",
+			wantAddress: "example.com:8443",
+		},
+		{
+			name:        "test URL scheme port",
+			target:      "LDAPS://ldap.example.com",
+			want:        &Target{Host: "ldap.example.com", Port: "636"},
+			wantString:  "ldap.example.com:636",
+			wantAddress: "ldap.example.com:636",
+		},
+		{
+			name:        "test URL with credentials",
+			target:      "https://user:p@ss@example.com/login",
+			want:        &Target{Host: "example.com", Port: "443"},
+			wantString:  "example.com",
+			wantAddress: "example.com:443",
+		},
+		{
+			name:        "test URL with host and IP",
+			target:      "https://shop.example.com@10.0.0.5/",
+			want:        &Target{Host: "shop.example.com", Port: "443", IP: "10.0.0.5"},
+			wantString:  "shop.example.com@10.0.0.5",
+			wantAddress: "10.0.0.5:443",
+		},
+		{
+			name:        "test URL with IPv6",
+			target:      "https://[2001:db8::1]:8443/path",
+			want:        &Target{Host: "2001:db8::1", Port: "8443"},
+			wantString:  "[2001:db8::1]:8443",
+			wantAddress: "[2001:db8::1]:8443",
+		},
+		{
+			name:        "test host with path",
+			target:      "example.com/path",
+			want:        &Target{Host: "example.com", Port: "443"},
+			wantString:  "example.com",
+			wantAddress: "example.com:443",
+		},
+		{
+			name:        "test upper case host with trailing dot",
+			target:      "WWW.Example.COM.:8443",
+			want:        &Target{Host: "www.example.com", Port: "8443"},
+			wantString:  "www.example.com:8443",
+			wantAddress: "www.example.com:8443",
+		},
+		{
+			name:        "test IDN host",
+			target:      "München.de",
+			want:        &Target{Host: "xn--mnchen-3ya.de", Port: "443"},
+			wantString:  "xn--mnchen-3ya.de",
+			wantAddress: "xn--mnchen-3ya.de:443",
+		},
+		{
+			name:        "test IDN host with IP",
+			target:      "smtp://почта.рф@10.0.0.7",
+			want:        &Target{Protocol: "smtp", Host: "xn--80a1acny.xn--p1ai", Port: "25", IP: "10.0.0.7"},
+			wantString:  "smtp://xn--80a1acny.xn--p1ai@10.0.0.7",
+			wantAddress: "10.0.0.7:25",
+		},
+		{
+			name:    "test wildcard host",
+			target:  "*.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "test host with invalid characters",
+			target:  "exa$mple.com",
+			wantErr: true,
+		},
+		{
+			name:    "test host with empty label",
+			target:  "example..com",
+			wantErr: true,
+		},
+		{
+			name:    "test host with too long label",
+			target:  strings.Repeat("a", 64) + ".example.com",
+			wantErr: true,
+		},
+		{
+			name:    "test URL without host",
+			target:  "https:///path",
+			wantErr: true,
+		},
+		{
+			name:    "test host with not IP",
+			target:  "shop.example.com@backend.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "test host with port before IP",
+			target:  "shop.example.com:443@10.0.0.5",
+			wantErr: true,
+		},
+		{
+			name:    "test empty host before IP",
+			target:  "@10.0.0.5",
+			wantErr: true,
+		},
+		{
+			name:    "test host with IP and incorrect port",
+			target:  "shop.example.com@10.0.0.5:0",
+			wantErr: true,
+		},
+		{
+			name:    "test unsupported protocol",
+			target:  "gopher://example.com",
+			wantErr: true,
+		},
+		{
+			name:    "test protocol without host",
+			target:  "imap://",
+			wantErr: true,
+		},
+		{
+			name:    "test empty target",
+			target:  " ",
+			wantErr: true,
+		},
+		{
+			name:    "test empty host",
+			target:  ":8443",
+			wantErr: true,
+		},
+		{
+			name:    "test not number port",
+			target:  "google.com:https",
+			wantErr: true,
+		},
+		{
+			name:    "test port out of range",
+			target:  "google.com:70000",
+			wantErr: true,
+		},
+		{
+			name:    "test bracketed not IPv6",
+			target:  "[google.com]:443",
+			wantErr: true,
+		},
+		{
+			name:    "test incorrect IPv6",
+			target:  "2001:db8::1:::443",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTarget(tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseTarget() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTarget() got = %v, want %v", got, tt.want)
+			}
+			if got.String() != tt.wantString {
+				t.Errorf("ParseTarget().String() got = %v, want %v", got.String(), tt.wantString)
+			}
+			if got.Address() != tt.wantAddress {
+				t.Errorf("ParseTarget().Address() got = %v, want %v", got.Address(), tt.wantAddress)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package certinfo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/net/dns/dnsmessage"
+	"net"
+	"strings"
+	"time"
+)
+
+//TLSA findings
+const (
+	FindingTLSAMismatch FindingCode = "tlsa_mismatch"
+	FindingTLSAError    FindingCode = "tlsa_error"
+)
+
+//typeTLSA - DNS type of TLSA records (RFC 6698)
+const typeTLSA dnsmessage.Type = 52
+
+//TLSA certificate usages (RFC 7218)
+const (
+	TLSAUsagePKIXTA = 0
+	TLSAUsagePKIXEE = 1
+	TLSAUsageDANETA = 2
+	TLSAUsageDANEEE = 3
+)
+
+//TLSA selectors and matching types (RFC 7218)
+const (
+	TLSASelectorCert = 0
+	TLSASelectorSPKI = 1
+
+	TLSAMatchingFull   = 0
+	TLSAMatchingSHA256 = 1
+	TLSAMatchingSHA512 = 2
+)
+
+//TLSARecord single TLSA record
+type TLSARecord struct {
+	Usage        uint8 `json:"usage"`
+	Selector     uint8 `json:"selector"`
+	MatchingType uint8 `json:"matching_type"`
+	//Data - hex encoded certificate association data
+	Data string `json:"data"`
+}
+
+//String returns record in presentation format, long data is shortened
+func (r TLSARecord) String() string {
+	data := r.Data
+	if len(data) > 16 {
+		data = data[:16] + "..."
+	}
+	return fmt.Sprintf("%d %d %d %s", r.Usage, r.Selector, r.MatchingType, data)
+}
+
+//TLSAResolver resolves TLSA records
+//Returns empty list without error if name has no TLSA records or does not exist
+//authenticated - answer is validated with DNSSEC, TLSA records without DNSSEC must not be trusted (RFC 6698, 4.1)
+type TLSAResolver interface {
+	LookupTLSA(ctx context.Context, name string) (records []TLSARecord, authenticated bool, err error)
+}
+
+//TLSAReport result of DANE check of target endpoint
+type TLSAReport struct {
+	//Name - queried name in _port._tcp.host format
+	Name    string       `json:"name"`
+	Records []TLSARecord `json:"records"`
+	//Matched - TLSA record, which matches served chain. Nil if there is no matching record
+	Matched *TLSARecord `json:"matched,omitempty"`
+	//Authenticated - TLSA records are validated with DNSSEC
+	Authenticated bool `json:"authenticated"`
+}
+
+//LookupTLSA queries TLSA records of name, answer is authenticated if nameserver sets AD bit
+func (r *DNSResolver) LookupTLSA(ctx context.Context, name string) ([]TLSARecord, bool, error) {
+	answers, authenticated, err := r.lookup(ctx, name, typeTLSA)
+	if err != nil {
+		return nil, false, err
+	}
+	var records []TLSARecord
+	for _, data := range answers {
+		record, err := parseTLSARecord(data)
+		if err != nil {
+			return nil, false, err
+		}
+		records = append(records, record)
+	}
+	return records, authenticated, nil
+}
+
+//parseTLSARecord parses TLSA record data: usage, selector, matching type and association data (RFC 6698, 2.1)
+func parseTLSARecord(data []byte) (TLSARecord, error) {
+	if len(data) < 4 {
+		return TLSARecord{}, errors.New("dns resolver error - malformed TLSA record")
+	}
+	return TLSARecord{Usage: data[0], Selector: data[1], MatchingType: data[2], Data: strings.ToUpper(hex.EncodeToString(data[3:]))}, nil
+}
+
+//TLSAName returns name of TLSA records of TCP service on host and port
+func TLSAName(host, port string) string {
+	return fmt.Sprintf("_%s._tcp.%s", port, strings.TrimSuffix(host, "."))
+}
+
+//CheckTLSA looks up TLSA records of target endpoint and matches them with served chain
+//pkixValid - served chain is valid for target host with trusted roots, it is required by PKIX-TA and PKIX-EE usages
+//Mismatch is critical only if TLSA records are validated with DNSSEC, otherwise records can be spoofed and mismatch is warning
+//Returns nil report if TLSAResolver is not set, host is IP address or endpoint has no TLSA records
+func (c *Checker) CheckTLSA(ctx context.Context, host, port string, certs []*x509.Certificate, pkixValid bool, now time.Time) (*TLSAReport, []Finding) {
+	if c.TLSAResolver == nil || net.ParseIP(host) != nil || len(certs) == 0 {
+		return nil, nil
+	}
+
+	name := TLSAName(host, port)
+	records, authenticated, err := c.TLSAResolver.LookupTLSA(ctx, name)
+	if err != nil {
+		return nil, []Finding{{
+			Code:     FindingTLSAError,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("cannot check TLSA records of %s - %v", name, err),
+		}}
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	report := &TLSAReport{Name: name, Records: records, Authenticated: authenticated}
+	//PKIX-TA trust anchor can be root from trusted roots, which is not served
+	var chains [][]*x509.Certificate
+	if pkixValid {
+		chains = verifiedChains(certs, c.Roots, now)
+	}
+	for i, record := range records {
+		if tlsaMatches(record, certs, chains, pkixValid, now) {
+			report.Matched = &report.Records[i]
+			return report, nil
+		}
+	}
+
+	var descriptions []string
+	for _, record := range records {
+		descriptions = append(descriptions, record.String())
+	}
+	finding := Finding{
+		Code:     FindingTLSAMismatch,
+		Severity: SeverityCritical,
+		Message:  fmt.Sprintf("served certificate chain does not match any TLSA record of %s (%s)", name, strings.Join(descriptions, "; ")),
+	}
+	if !authenticated {
+		finding.Severity = SeverityWarning
+		finding.Message += ", TLSA records are not validated with DNSSEC"
+	}
+	return report, []Finding{finding}
+}
+
+//tlsaMatches checks that TLSA record matches served chain according to certificate usage (RFC 6698, 2.1.1 and RFC 7671)
+//chains - chains of served certificates verified with trusted roots, they are used by PKIX-TA usage
+//Records with unknown usage, selector or matching type are not usable and never match
+func tlsaMatches(record TLSARecord, certs []*x509.Certificate, chains [][]*x509.Certificate, pkixValid bool, now time.Time) bool {
+	data, err := hex.DecodeString(record.Data)
+	if err != nil {
+		return false
+	}
+	leaf := certs[0]
+	switch record.Usage {
+	case TLSAUsagePKIXEE:
+		return pkixValid && tlsaCertMatches(record, data, leaf)
+	case TLSAUsageDANEEE:
+		//DANE-EE does not require chain validation, expiry and name checks (RFC 7671, 5.1)
+		return tlsaCertMatches(record, data, leaf)
+	case TLSAUsagePKIXTA:
+		if !pkixValid {
+			return false
+		}
+		for _, chain := range chains {
+			for _, cert := range chain[1:] {
+				if tlsaCertMatches(record, data, cert) {
+					return true
+				}
+			}
+		}
+	case TLSAUsageDANETA:
+		//trust anchor must be served, leaf must be issued by trust anchor through served intermediates
+		for i, cert := range certs {
+			if i == 0 || !tlsaCertMatches(record, data, cert) {
+				continue
+			}
+			roots := x509.NewCertPool()
+			roots.AddCert(cert)
+			intermediates := x509.NewCertPool()
+			for _, intermediate := range certs[1:i] {
+				intermediates.AddCert(intermediate)
+			}
+			if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, CurrentTime: now}); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//tlsaCertMatches checks that selected part of certificate matches association data
+func tlsaCertMatches(record TLSARecord, data []byte, cert *x509.Certificate) bool {
+	var selected []byte
+	switch record.Selector {
+	case TLSASelectorCert:
+		selected = cert.Raw
+	case TLSASelectorSPKI:
+		selected = cert.RawSubjectPublicKeyInfo
+	default:
+		return false
+	}
+	switch record.MatchingType {
+	case TLSAMatchingFull:
+		return bytes.Equal(selected, data)
+	case TLSAMatchingSHA256:
+		hash := sha256.Sum256(selected)
+		return bytes.Equal(hash[:], data)
+	case TLSAMatchingSHA512:
+		hash := sha512.Sum512(selected)
+		return bytes.Equal(hash[:], data)
+	}
+	return false
+}
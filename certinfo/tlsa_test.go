@@ -0,0 +1,346 @@
+package certinfo
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"golang.org/x/net/dns/dnsmessage"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+//testTLSAResolver resolves TLSA records from map, names from errors map return error
+//answers are authenticated, except names from unauthenticated map
+type testTLSAResolver struct {
+	records         map[string][]TLSARecord
+	errors          map[string]error
+	unauthenticated map[string]bool
+}
+
+func (r *testTLSAResolver) LookupTLSA(_ context.Context, name string) ([]TLSARecord, bool, error) {
+	if err, ok := r.errors[name]; ok {
+		return nil, false, err
+	}
+	return r.records[name], !r.unauthenticated[name], nil
+}
+
+//newTestTLSARecord creates TLSA record of certificate
+func newTestTLSARecord(usage, selector, matchingType uint8, cert *x509.Certificate) TLSARecord {
+	selected := cert.Raw
+	if selector == TLSASelectorSPKI {
+		selected = cert.RawSubjectPublicKeyInfo
+	}
+	data := selected
+	switch matchingType {
+	case TLSAMatchingSHA256:
+		hash := sha256.Sum256(selected)
+		data = hash[:]
+	case TLSAMatchingSHA512:
+		hash := sha512.Sum512(selected)
+		data = hash[:]
+	}
+	return TLSARecord{Usage: usage, Selector: selector, MatchingType: matchingType, Data: strings.ToUpper(hex.EncodeToString(data))}
+}
+
+func TestChecker_CheckTLSA(t *testing.T) {
+	leaf, intermediate, root := newTestChain(t, "mail.example.com")
+	otherLeaf, _, otherRoot := newTestChain(t, "mail.example.com")
+	chain := []*x509.Certificate{leaf.cert, intermediate.cert}
+	name := "_25._tcp.mail.example.com"
+	roots, err := NewRootPool([]*x509.Certificate{root.cert}, true)
+	if err != nil {
+		t.Fatalf("NewRootPool() error = %v", err)
+	}
+
+	tests := []struct {
+		name             string
+		resolver         TLSAResolver
+		host             string
+		certs            []*x509.Certificate
+		pkixValid        bool
+		records          []TLSARecord
+		wantReport       bool
+		wantMatched      int
+		wantFindingCodes []FindingCode
+		wantSeverity     Severity
+	}{
+		{
+			name:        "test DANE-EE SPKI SHA-256",
+			records:     []TLSARecord{newTestTLSARecord(TLSAUsageDANEEE, TLSASelectorSPKI, TLSAMatchingSHA256, leaf.cert)},
+			wantReport:  true,
+			wantMatched: 0,
+		},
+		{
+			name:        "test DANE-EE full certificate",
+			records:     []TLSARecord{newTestTLSARecord(TLSAUsageDANEEE, TLSASelectorCert, TLSAMatchingFull, leaf.cert)},
+			wantReport:  true,
+			wantMatched: 0,
+		},
+		{
+			name:        "test DANE-EE certificate SHA-512",
+			records:     []TLSARecord{newTestTLSARecord(TLSAUsageDANEEE, TLSASelectorCert, TLSAMatchingSHA512, leaf.cert)},
+			wantReport:  true,
+			wantMatched: 0,
+		},
+		{
+			name:             "test DANE-EE of rotated certificate",
+			records:          []TLSARecord{newTestTLSARecord(TLSAUsageDANEEE, TLSASelectorSPKI, TLSAMatchingSHA256, otherLeaf.cert)},
+			wantReport:       true,
+			wantMatched:      -1,
+			wantFindingCodes: []FindingCode{FindingTLSAMismatch},
+			wantSeverity:     SeverityCritical,
+		},
+		{
+			name: "test mismatch of records without DNSSEC",
+			resolver: &testTLSAResolver{
+				records:         map[string][]TLSARecord{name: {newTestTLSARecord(TLSAUsageDANEEE, TLSASelectorSPKI, TLSAMatchingSHA256, otherLeaf.cert)}},
+				unauthenticated: map[string]bool{name: true},
+			},
+			wantReport:       true,
+			wantMatched:      -1,
+			wantFindingCodes: []FindingCode{FindingTLSAMismatch},
+			wantSeverity:     SeverityWarning,
+		},
+		{
+			name: "test second record matches",
+			records: []TLSARecord{
+				newTestTLSARecord(TLSAUsageDANEEE, TLSASelectorSPKI, TLSAMatchingSHA256, otherLeaf.cert),
+				newTestTLSARecord(TLSAUsageDANEEE, TLSASelectorSPKI, TLSAMatchingSHA256, leaf.cert),
+			},
+			wantReport:  true,
+			wantMatched: 1,
+		},
+		{
+			name:        "test DANE-TA served intermediate",
+			records:     []TLSARecord{newTestTLSARecord(TLSAUsageDANETA, TLSASelectorCert, TLSAMatchingSHA256, intermediate.cert)},
+			wantReport:  true,
+			wantMatched: 0,
+		},
+		{
+			name:        "test DANE-TA served root",
+			certs:       []*x509.Certificate{leaf.cert, intermediate.cert, root.cert},
+			records:     []TLSARecord{newTestTLSARecord(TLSAUsageDANETA, TLSASelectorSPKI, TLSAMatchingSHA256, root.cert)},
+			wantReport:  true,
+			wantMatched: 0,
+		},
+		{
+			name:             "test DANE-TA root is not served",
+			records:          []TLSARecord{newTestTLSARecord(TLSAUsageDANETA, TLSASelectorSPKI, TLSAMatchingSHA256, root.cert)},
+			wantReport:       true,
+			wantMatched:      -1,
+			wantFindingCodes: []FindingCode{FindingTLSAMismatch},
+		},
+		{
+			name:             "test DANE-TA leaf",
+			records:          []TLSARecord{newTestTLSARecord(TLSAUsageDANETA, TLSASelectorSPKI, TLSAMatchingSHA256, leaf.cert)},
+			wantReport:       true,
+			wantMatched:      -1,
+			wantFindingCodes: []FindingCode{FindingTLSAMismatch},
+		},
+		{
+			name:        "test PKIX-EE valid chain",
+			pkixValid:   true,
+			records:     []TLSARecord{newTestTLSARecord(TLSAUsagePKIXEE, TLSASelectorSPKI, TLSAMatchingSHA256, leaf.cert)},
+			wantReport:  true,
+			wantMatched: 0,
+		},
+		{
+			name:             "test PKIX-EE invalid chain",
+			records:          []TLSARecord{newTestTLSARecord(TLSAUsagePKIXEE, TLSASelectorSPKI, TLSAMatchingSHA256, leaf.cert)},
+			wantReport:       true,
+			wantMatched:      -1,
+			wantFindingCodes: []FindingCode{FindingTLSAMismatch},
+		},
+		{
+			name:        "test PKIX-TA valid chain",
+			pkixValid:   true,
+			records:     []TLSARecord{newTestTLSARecord(TLSAUsagePKIXTA, TLSASelectorCert, TLSAMatchingSHA256, intermediate.cert)},
+			wantReport:  true,
+			wantMatched: 0,
+		},
+		{
+			name:             "test PKIX-TA invalid chain",
+			records:          []TLSARecord{newTestTLSARecord(TLSAUsagePKIXTA, TLSASelectorCert, TLSAMatchingSHA256, intermediate.cert)},
+			wantReport:       true,
+			wantMatched:      -1,
+			wantFindingCodes: []FindingCode{FindingTLSAMismatch},
+		},
+		{
+			name:        "test PKIX-TA trusted root is not served",
+			pkixValid:   true,
+			records:     []TLSARecord{newTestTLSARecord(TLSAUsagePKIXTA, TLSASelectorSPKI, TLSAMatchingSHA256, root.cert)},
+			wantReport:  true,
+			wantMatched: 0,
+		},
+		{
+			name:             "test PKIX-TA root of other chain",
+			pkixValid:        true,
+			records:          []TLSARecord{newTestTLSARecord(TLSAUsagePKIXTA, TLSASelectorSPKI, TLSAMatchingSHA256, otherRoot.cert)},
+			wantReport:       true,
+			wantMatched:      -1,
+			wantFindingCodes: []FindingCode{FindingTLSAMismatch},
+		},
+		{
+			name: "test unusable records",
+			records: []TLSARecord{
+				{Usage: 4, Selector: TLSASelectorSPKI, MatchingType: TLSAMatchingSHA256, Data: newTestTLSARecord(0, TLSASelectorSPKI, TLSAMatchingSHA256, leaf.cert).Data},
+				{Usage: TLSAUsageDANEEE, Selector: 2, MatchingType: TLSAMatchingSHA256, Data: newTestTLSARecord(0, TLSASelectorSPKI, TLSAMatchingSHA256, leaf.cert).Data},
+				{Usage: TLSAUsageDANEEE, Selector: TLSASelectorSPKI, MatchingType: 3, Data: newTestTLSARecord(0, TLSASelectorSPKI, TLSAMatchingSHA256, leaf.cert).Data},
+			},
+			wantReport:       true,
+			wantMatched:      -1,
+			wantFindingCodes: []FindingCode{FindingTLSAMismatch},
+		},
+		{
+			name: "test no TLSA records",
+		},
+		{
+			name:             "test resolver error",
+			resolver:         &testTLSAResolver{errors: map[string]error{name: errors.New("server failure")}},
+			wantFindingCodes: []FindingCode{FindingTLSAError},
+		},
+		{
+			name:    "test IP address",
+			host:    "127.0.0.1",
+			records: []TLSARecord{newTestTLSARecord(TLSAUsageDANEEE, TLSASelectorSPKI, TLSAMatchingSHA256, otherLeaf.cert)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := tt.resolver
+			if resolver == nil {
+				resolver = &testTLSAResolver{records: map[string][]TLSARecord{name: tt.records, "_25._tcp.127.0.0.1": tt.records}}
+			}
+			host := tt.host
+			if host == "" {
+				host = "mail.example.com"
+			}
+			certs := tt.certs
+			if certs == nil {
+				certs = chain
+			}
+
+			checker := (&Checker{TLSAResolver: resolver}).WithRoots(roots)
+			report, findings := checker.CheckTLSA(context.Background(), host, "25", certs, tt.pkixValid, time.Now())
+			if (report != nil) != tt.wantReport {
+				t.Fatalf("CheckTLSA() report = %v, want report %v", report, tt.wantReport)
+			}
+			if report != nil {
+				matched := -1
+				for i := range report.Records {
+					if report.Matched == &report.Records[i] {
+						matched = i
+					}
+				}
+				if report.Name != name || matched != tt.wantMatched {
+					t.Errorf("CheckTLSA() name = %v, matched record = %v, want %v, %v", report.Name, matched, name, tt.wantMatched)
+				}
+			}
+			if got := findingsCodes(findings); !reflect.DeepEqual(got, tt.wantFindingCodes) {
+				t.Errorf("CheckTLSA() findings = %v, want %v", got, tt.wantFindingCodes)
+			}
+			if tt.wantSeverity != "" && (len(findings) == 0 || findings[0].Severity != tt.wantSeverity) {
+				t.Errorf("CheckTLSA() findings = %v, want severity %v", findings, tt.wantSeverity)
+			}
+		})
+	}
+
+	if report, _ := (&Checker{}).CheckTLSA(context.Background(), "mail.example.com", "25", chain, true, time.Now()); report != nil {
+		t.Errorf("CheckTLSA() without resolver report = %v, want nil", report)
+	}
+}
+
+func TestTLSAName(t *testing.T) {
+	tests := []struct {
+		host string
+		port string
+		want string
+	}{
+		{host: "mail.example.com", port: "25", want: "_25._tcp.mail.example.com"},
+		{host: "imap.example.com.", port: "993", want: "_993._tcp.imap.example.com"},
+		{host: "example.com", port: "443", want: "_443._tcp.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := TLSAName(tt.host, tt.port); got != tt.want {
+				t.Errorf("TLSAName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSResolver_LookupTLSA(t *testing.T) {
+	server := &testDNSServer{
+		records: map[string][]dnsmessage.UnknownResource{
+			"_25._tcp.mail.example.com": {
+				{Type: typeTLSA, Data: []byte{3, 1, 1, 0xAB, 0xCD}},
+				caaResource(CAARecord{Tag: "issue", Value: "letsencrypt.org"}),
+			},
+			"_993._tcp.mail.example.com": {{Type: typeTLSA, Data: []byte{3, 1}}},
+			"_443._tcp.mail.example.com": {{Type: typeTLSA, Data: []byte{2, 0, 1, 0xEF}}},
+		},
+		authenticated: map[string]bool{"_443._tcp.mail.example.com": true},
+	}
+	resolver, err := NewDNSResolver(server.start(t))
+	if err != nil {
+		t.Fatalf("NewDNSResolver() error = %v", err)
+	}
+
+	tests := []struct {
+		name              string
+		want              []TLSARecord
+		wantAuthenticated bool
+		wantErr           bool
+	}{
+		{
+			name: "_25._tcp.mail.example.com",
+			want: []TLSARecord{{Usage: 3, Selector: 1, MatchingType: 1, Data: "ABCD"}},
+		},
+		{
+			name:              "_443._tcp.mail.example.com",
+			want:              []TLSARecord{{Usage: 2, Selector: 0, MatchingType: 1, Data: "EF"}},
+			wantAuthenticated: true,
+		},
+		{
+			name:    "_993._tcp.mail.example.com",
+			wantErr: true,
+		},
+		{
+			name: "_465._tcp.mail.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, authenticated, err := resolver.LookupTLSA(context.Background(), tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LookupTLSA() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) || authenticated != tt.wantAuthenticated {
+				t.Errorf("LookupTLSA() = %v, %v, want %v, %v", got, authenticated, tt.want, tt.wantAuthenticated)
+			}
+		})
+	}
+}
+
+func TestChecker_Check_TLSA(t *testing.T) {
+	leaf, intermediate, _ := newTestChain(t, "mail.example.com")
+	address := startTestTLSServer(t, newTestTLSConfig(leaf, intermediate))
+	_, port, _ := net.SplitHostPort(address)
+	resolver := &testTLSAResolver{records: map[string][]TLSARecord{
+		TLSAName("mail.example.com", port): {newTestTLSARecord(TLSAUsageDANEEE, TLSASelectorSPKI, TLSAMatchingSHA256, leaf.cert)},
+	}}
+
+	report := (&Checker{TLSAResolver: resolver}).Check(context.Background(), "mail.example.com@"+address)
+	if report.Err != nil {
+		t.Fatalf("Check() error = %v", report.Err)
+	}
+	if report.TLSA == nil || report.TLSA.Matched == nil {
+		t.Errorf("Check() TLSA = %+v, want matched record", report.TLSA)
+	}
+}
@@ -0,0 +1,210 @@
+package certinfo
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+)
+
+//FindingCode code of problem found while certificate check
+type FindingCode string
+
+const (
+	FindingUntrustedRoot       FindingCode = "untrusted_root"
+	FindingMissingIntermediate FindingCode = "missing_intermediate"
+	FindingHostnameMismatch    FindingCode = "hostname_mismatch"
+	FindingNotYetValid         FindingCode = "not_yet_valid"
+	FindingExpired             FindingCode = "expired"
+	FindingInvalidChain        FindingCode = "invalid_chain"
+	FindingNodeCertMismatch    FindingCode = "node_certificate_mismatch"
+	FindingNodeUnreachable     FindingCode = "node_unreachable"
+)
+
+//Severity of finding
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+//Finding problem found while certificate check
+type Finding struct {
+	Code     FindingCode `json:"code"`
+	Severity Severity    `json:"severity"`
+	Message  string      `json:"message"`
+}
+
+//String returns finding message with severity mark
+func (f Finding) String() string {
+	switch f.Severity {
+	case SeverityCritical:
+		return "❌ " + f.Message
+	case SeverityWarning:
+		return "⚠️ " + f.Message
+	default:
+		return "ℹ️ " + f.Message
+	}
+}
+
+//VerifyChain verifies served certificates chain separately against roots and requested host
+//certs - served chain, first certificate must be a leaf
+//host - requested host. If host is empty - hostname is not verified
+//roots - trusted roots. If roots is nil - system roots are used
+//now - time for validity checks
+func VerifyChain(certs []*x509.Certificate, host string, roots *x509.CertPool, now time.Time) []Finding {
+	if len(certs) == 0 {
+		return []Finding{{
+			Code:     FindingInvalidChain,
+			Severity: SeverityCritical,
+			Message:  "server did not send any certificate",
+		}}
+	}
+
+	var findings []Finding
+	leaf := certs[0]
+
+	for i, cert := range certs {
+		if now.Before(cert.NotBefore) {
+			findings = append(findings, Finding{
+				Code:     FindingNotYetValid,
+				Severity: SeverityCritical,
+				Message:  fmt.Sprintf("%s %s is not valid before %s", chainPositionName(i), cert.Subject.CommonName, cert.NotBefore.Format("2006-01-02 15:04:05")),
+			})
+		}
+		if now.After(cert.NotAfter) {
+			findings = append(findings, Finding{
+				Code:     FindingExpired,
+				Severity: SeverityCritical,
+				Message:  fmt.Sprintf("%s %s expired at %s", chainPositionName(i), cert.Subject.CommonName, cert.NotAfter.Format("2006-01-02 15:04:05")),
+			})
+		}
+	}
+
+	if err := leaf.VerifyHostname(host); host != "" && err != nil {
+		findings = append(findings, Finding{
+			Code:     FindingHostnameMismatch,
+			Severity: SeverityCritical,
+			Message:  fmt.Sprintf("certificate is not valid for %s. Certificate names: %v", host, leaf.DNSNames),
+		})
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	}
+	_, err := leaf.Verify(opts)
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired {
+		//validity is already checked, check trust in the middle of leaf validity period
+		opts.CurrentTime = leaf.NotBefore.Add(leaf.NotAfter.Sub(leaf.NotBefore) / 2)
+		_, err = leaf.Verify(opts)
+	}
+	if err != nil {
+		findings = append(findings, chainFinding(err, certs))
+	}
+
+	return findings
+}
+
+//chainFinding converts chain verification error to finding
+//Served chain is checked up to first certificate, which is not signed by next served certificate
+func chainFinding(err error, certs []*x509.Certificate) Finding {
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthorityErr) {
+		index := chainBreak(certs)
+		broken := certs[index]
+		if isSelfSigned(broken) {
+			if index == 0 {
+				return Finding{
+					Code:     FindingUntrustedRoot,
+					Severity: SeverityCritical,
+					Message:  fmt.Sprintf("certificate is self-signed (%s)", broken.Subject),
+				}
+			}
+			return Finding{
+				Code:     FindingUntrustedRoot,
+				Severity: SeverityCritical,
+				Message:  fmt.Sprintf("certificate chain is signed by untrusted root %s", broken.Subject),
+			}
+		}
+		return Finding{
+			Code:     FindingMissingIntermediate,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("certificate chain is incomplete or untrusted - issuer %s is not found (missing intermediate)", broken.Issuer),
+		}
+	}
+	return Finding{
+		Code:     FindingInvalidChain,
+		Severity: SeverityCritical,
+		Message:  fmt.Sprintf("certificate chain is invalid - %v", err),
+	}
+}
+
+//chainTrusted returns true if findings have no problems of chain trust
+func chainTrusted(findings []Finding) bool {
+	for _, finding := range findings {
+		switch finding.Code {
+		case FindingUntrustedRoot, FindingMissingIntermediate, FindingInvalidChain:
+			return false
+		}
+	}
+	return true
+}
+
+//pkixValid returns true if findings have no problems of chain trust, host name and validity period
+func pkixValid(findings []Finding) bool {
+	if !chainTrusted(findings) {
+		return false
+	}
+	for _, finding := range findings {
+		switch finding.Code {
+		case FindingHostnameMismatch, FindingExpired, FindingNotYetValid:
+			return false
+		}
+	}
+	return true
+}
+
+//chainBreak returns index of first served certificate, which is not signed by next served certificate
+//Returns index of last certificate, if every served certificate is signed by next one
+func chainBreak(certs []*x509.Certificate) int {
+	for i := 0; i < len(certs)-1; i++ {
+		if certs[i].CheckSignatureFrom(certs[i+1]) != nil {
+			return i
+		}
+	}
+	return len(certs) - 1
+}
+
+//chainIssuer returns issuer of leaf certificate from served chain, nil if chain has no issuer
+func chainIssuer(certs []*x509.Certificate) *x509.Certificate {
+	if len(certs) < 2 {
+		return nil
+	}
+	return certs[1]
+}
+
+//isSelfSigned checks that certificate is signed by itself
+func isSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+//chainPositionName returns name of certificate position in chain
+func chainPositionName(position int) string {
+	if position == 0 {
+		return "leaf certificate"
+	}
+	return fmt.Sprintf("chain certificate #%d", position)
+}
@@ -0,0 +1,134 @@
+package certinfo
+
+import (
+	"crypto/x509"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func findingsCodes(findings []Finding) []FindingCode {
+	var codes []FindingCode
+	for _, finding := range findings {
+		codes = append(codes, finding.Code)
+	}
+	return codes
+}
+
+func TestVerifyChain(t *testing.T) {
+	leaf, intermediate, root := newTestChain(t, "example.com")
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+
+	now := time.Now()
+	expiredLeaf := newTestCert(t, testCertOptions{
+		commonName: "example.com",
+		dnsNames:   []string{"example.com"},
+		notBefore:  now.Add(-48 * time.Hour),
+		notAfter:   now.Add(-24 * time.Hour),
+	}, intermediate)
+	notYetValidLeaf := newTestCert(t, testCertOptions{
+		commonName: "example.com",
+		dnsNames:   []string{"example.com"},
+		notBefore:  now.Add(24 * time.Hour),
+		notAfter:   now.Add(48 * time.Hour),
+	}, intermediate)
+	selfSignedLeaf := newTestCert(t, testCertOptions{
+		commonName: "example.com",
+		dnsNames:   []string{"example.com"},
+	}, nil)
+
+	tests := []struct {
+		name  string
+		certs []*x509.Certificate
+		host  string
+		roots *x509.CertPool
+		want  []FindingCode
+	}{
+		{
+			name:  "test valid chain",
+			certs: []*x509.Certificate{leaf.cert, intermediate.cert},
+			host:  "example.com",
+			roots: roots,
+			want:  nil,
+		},
+		{
+			name:  "test valid chain with served root",
+			certs: []*x509.Certificate{leaf.cert, intermediate.cert, root.cert},
+			host:  "example.com",
+			roots: roots,
+			want:  nil,
+		},
+		{
+			name:  "test untrusted root",
+			certs: []*x509.Certificate{leaf.cert, intermediate.cert, root.cert},
+			host:  "example.com",
+			roots: x509.NewCertPool(),
+			want:  []FindingCode{FindingUntrustedRoot},
+		},
+		{
+			name:  "test self-signed",
+			certs: []*x509.Certificate{selfSignedLeaf.cert},
+			host:  "example.com",
+			roots: roots,
+			want:  []FindingCode{FindingUntrustedRoot},
+		},
+		{
+			name:  "test missing intermediate",
+			certs: []*x509.Certificate{leaf.cert},
+			host:  "example.com",
+			roots: roots,
+			want:  []FindingCode{FindingMissingIntermediate},
+		},
+		{
+			name:  "test missing intermediate with served root",
+			certs: []*x509.Certificate{leaf.cert, root.cert},
+			host:  "example.com",
+			roots: roots,
+			want:  []FindingCode{FindingMissingIntermediate},
+		},
+		{
+			name:  "test missing intermediate with untrusted served root",
+			certs: []*x509.Certificate{leaf.cert, root.cert},
+			host:  "example.com",
+			roots: x509.NewCertPool(),
+			want:  []FindingCode{FindingMissingIntermediate},
+		},
+		{
+			name:  "test hostname mismatch",
+			certs: []*x509.Certificate{leaf.cert, intermediate.cert},
+			host:  "other.com",
+			roots: roots,
+			want:  []FindingCode{FindingHostnameMismatch},
+		},
+		{
+			name:  "test expired",
+			certs: []*x509.Certificate{expiredLeaf.cert, intermediate.cert},
+			host:  "example.com",
+			roots: roots,
+			want:  []FindingCode{FindingExpired},
+		},
+		{
+			name:  "test not yet valid",
+			certs: []*x509.Certificate{notYetValidLeaf.cert, intermediate.cert},
+			host:  "example.com",
+			roots: roots,
+			want:  []FindingCode{FindingNotYetValid},
+		},
+		{
+			name:  "test no certificates",
+			certs: nil,
+			host:  "example.com",
+			roots: roots,
+			want:  []FindingCode{FindingInvalidChain},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := VerifyChain(tt.certs, tt.host, tt.roots, now)
+			if !reflect.DeepEqual(findingsCodes(got), tt.want) {
+				t.Errorf("VerifyChain() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
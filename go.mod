@@ -1,9 +1,14 @@
 module certcheckerbot
 
-go 1.18
+go 1.19
 
 require (
 	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
 	github.com/google/uuid v1.3.0
 	github.com/mattn/go-sqlite3 v1.14.14
+	golang.org/x/crypto v0.1.0
+	golang.org/x/net v0.1.0
+	software.sslmate.com/src/go-pkcs12 v0.2.0
 )
+
+require golang.org/x/text v0.4.0 // indirect
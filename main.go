@@ -2,16 +2,25 @@ package main
 
 import (
 	"certcheckerbot/botprocessing"
+	"certcheckerbot/certinfo"
 	"certcheckerbot/scheduler"
 	"certcheckerbot/storage"
 	"certcheckerbot/storage/sqlite3"
+	"context"
 	"encoding/json"
 	"log"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	dbPath := os.Getenv("DB_PATH")
 	db, err := sqlite3.NewController(dbPath)
 	if err != nil {
@@ -33,13 +42,63 @@ func main() {
 		days = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 60, 90}
 	}
 
-	myBot, err := botprocessing.NewBot(os.Getenv("BOT_KEY"), db, debug)
+	checker := &certinfo.Checker{
+		ConnectTimeout:    getEnvDuration("CONNECT_TIMEOUT", certinfo.DefaultConnectTimeout),
+		HandshakeTimeout:  getEnvDuration("HANDSHAKE_TIMEOUT", certinfo.DefaultHandshakeTimeout),
+		MaxParallelChecks: getEnvInt("MAX_PARALLEL_CHECKS", certinfo.DefaultMaxParallelChecks),
+		KeyPolicy: certinfo.KeyPolicy{
+			MinRSABits:      getEnvInt("MIN_RSA_KEY_BITS", certinfo.DefaultMinRSABits),
+			MaxValidityDays: getEnvInt("MAX_CERT_VALIDITY_DAYS", certinfo.DefaultMaxValidityDays),
+			AllowedCurves:   getEnvList("ALLOWED_CURVES"),
+		},
+		CRLCache: db,
+	}
+	if proxyURL := os.Getenv("PROXY_URL"); proxyURL != "" {
+		checker.Proxy, err = certinfo.ParseProxy(proxyURL, os.Getenv("NO_PROXY"))
+		if err != nil {
+			log.Panic(err)
+		}
+		log.Printf("Certificates are checked through proxy %s", checker.Proxy)
+	}
+	if dnsResolver, err := certinfo.NewDNSResolver(os.Getenv("DNS_NAMESERVER")); err != nil {
+		log.Printf("\nCAA and TLSA records are not checked - %v.\n", err)
+	} else {
+		checker.CAAResolver = dnsResolver
+		checker.TLSAResolver = dnsResolver
+	}
+	if logListPath := os.Getenv("CT_LOG_LIST"); logListPath != "" {
+		checker.CTLogs, err = certinfo.LoadCTLogList(logListPath)
+		if err != nil {
+			log.Panic(err)
+		}
+		log.Printf("SCTs are verified with %d Certificate Transparency logs from %s", checker.CTLogs.Len(), logListPath)
+	}
+
+	var clientCertificates certinfo.ClientCertificates
+	if clientCertsPath := os.Getenv("CLIENT_CERTS"); clientCertsPath != "" {
+		clientCertificates, err = certinfo.LoadClientCertificates(clientCertsPath)
+		if err != nil {
+			log.Panic(err)
+		}
+		log.Printf("Loaded %d client certificates from %s", len(clientCertificates), clientCertsPath)
+	}
+	if clientCertName := os.Getenv("CLIENT_CERT"); clientCertName != "" {
+		checker.ClientCertificate, err = clientCertificates.Get(clientCertName)
+		if err != nil {
+			log.Panic(err)
+		}
+		log.Printf("Client certificate %s is sent to added domains, which request it", clientCertName)
+	}
+
+	myBot, err := botprocessing.NewBot(os.Getenv("BOT_KEY"), db, checker, debug)
 	if err != nil {
 		log.Panic(err)
 	}
+	myBot.Admins = getEnvIDs("ADMIN_IDS")
+	myBot.ClientCertificates = clientCertificates
 
 	usersDomainsChan := make(chan *storage.User, 100)
-	errorsBot := myBot.StartProcessing(usersDomainsChan, days)
+	errorsBot := myBot.StartProcessing(ctx, usersDomainsChan, days)
 
 	go scheduler.InitScheduler(db, usersDomainsChan)
 
@@ -47,7 +106,66 @@ func main() {
 		select {
 		case err := <-errorsBot:
 			log.Printf("Bot error message: %s", err)
+		case <-ctx.Done():
+			log.Println("Bot stopped")
+			return
 		}
 	}
 
 }
+
+//getEnvDuration reads duration (for example "10s") from environment variable
+//returns defaultValue if variable is not set or incorrect
+func getEnvDuration(name string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("\nIncorrect %s value - %v. Default value set to %v.\n", name, value, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
+
+//getEnvInt reads positive integer number from environment variable
+//returns defaultValue if variable is not set or incorrect
+func getEnvInt(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		log.Printf("\nIncorrect %s value - %v. Default value set to %v.\n", name, value, defaultValue)
+		return defaultValue
+	}
+	return number
+}
+
+//getEnvList reads comma separated list from environment variable, empty entries are skipped
+func getEnvList(name string) []string {
+	var result []string
+	for _, entry := range strings.Split(os.Getenv(name), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry != "" {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+//getEnvIDs reads comma separated list of telegram ids from environment variable, incorrect ids are skipped
+func getEnvIDs(name string) []int64 {
+	var result []int64
+	for _, entry := range getEnvList(name) {
+		id, err := strconv.ParseInt(entry, 10, 64)
+		if err != nil {
+			log.Printf("\nIncorrect %s value - %v. Id is skipped.\n", name, entry)
+			continue
+		}
+		result = append(result, id)
+	}
+	return result
+}
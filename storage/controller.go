@@ -1,10 +1,15 @@
 package storage
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var ErrorUserNotFound = errors.New("storage error - user not found")
 var ErrorUserDomainNotFound = errors.New("storage error - user domain not found")
 var ErrorUsersSchedulesNotFound = errors.New("storage error - users schedules not found")
+var ErrorCRLNotFound = errors.New("storage error - CRL not found")
+var ErrorUserTrustStoreNotFound = errors.New("storage error - user trust store not found")
 
 type UsersConfig interface {
 	AddUser(user *User) (int, error)
@@ -16,7 +21,20 @@ type UsersConfig interface {
 
 	AddUserDomain(domain *UserDomain) (bool, error)
 	RemoveUserDomain(domain *UserDomain) (bool, error)
+	UpdateUserDomain(domain *UserDomain) (bool, error)
+	UpdateDomainCheckState(domain *UserDomain) (bool, error)
+	UpdateDomainAuditSummary(domain *UserDomain) (bool, error)
 	GetUserDomains(user *User) (*[]UserDomain, error)
 
+	SaveUserTrustStore(store *UserTrustStore) error
+	GetUserTrustStore(user *User) (*UserTrustStore, error)
+	RemoveUserTrustStore(user *User) (bool, error)
+
 	GetUsersSchedules() (*[]UserSchedule, error)
 }
+
+//CRLCache storage of downloaded CRLs
+type CRLCache interface {
+	GetCRL(URL string) ([]byte, time.Time, error)
+	SaveCRL(URL string, raw []byte, nextUpdate time.Time) error
+}
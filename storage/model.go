@@ -1,5 +1,7 @@
 package storage
 
+import "time"
+
 type User struct {
 	Id               int
 	Name             string
@@ -12,6 +14,41 @@ type User struct {
 type UserDomain struct {
 	UserId int
 	Domain string
+	//PinnedIP - IP address to check domain on instead of resolved addresses, empty if domain must be resolved
+	PinnedIP string
+	//Proxy - outbound proxy for domain checks, overrides global proxy. Empty if global proxy is used
+	//Proxy URL is stored in plaintext with credentials
+	Proxy string
+	//AuditEnabled - TLS settings of domain are audited by schedule
+	AuditEnabled bool
+	//AuditSummary - result of last scheduled TLS audit in JSON format, empty if domain was not audited
+	AuditSummary string
+	//CertFingerprint, CertSerial, CertIssuer, CertNotAfter - leaf certificate served on last scheduled check, empty if domain was not checked
+	CertFingerprint string
+	CertSerial      string
+	CertIssuer      string
+	CertNotAfter    time.Time
+	//ExpiryAlerted - expiry notification is sent for served leaf certificate, alert is closed when certificate is changed
+	ExpiryAlerted bool
+	//ProblemsHash - hash of certificate problems notified on last scheduled check, empty if domain has no problems
+	//The same problems are notified once, notification is sent again when problems are changed
+	ProblemsHash string
+	//CheckErrorCategory - error category of failed scheduled check, which is notified. Empty if last scheduled check succeeded
+	CheckErrorCategory string
+	//Pins - expected SPKI SHA-256 pins in base64 format, served chain must contain public key of any pin. Empty if domain is not pinned
+	Pins []string
+	//ClientCert - name of client certificate for mutual TLS from client certificates config, overrides global client certificate.
+	//Empty if global client certificate is used
+	ClientCert string
+}
+
+//UserTrustStore - CA bundle uploaded by user, it is used to verify chains of user domains
+type UserTrustStore struct {
+	UserId int
+	//Roots - trusted root certificates in PEM format
+	Roots []byte
+	//RootsOnly - only uploaded roots are trusted. If false - uploaded roots are trusted in addition to system roots
+	RootsOnly bool
 }
 
 type UserSchedule struct {
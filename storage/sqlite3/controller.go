@@ -3,8 +3,16 @@ package sqlite3
 import (
 	"certcheckerbot/storage"
 	"database/sql"
+	"strings"
+	"time"
 )
 
+//pinsSeparator - separator of stored SPKI pins, base64 pins do not contain it
+const pinsSeparator = ","
+
+//crlRetention - period after next update time of CRL while stale CRL is kept in cache as fallback
+const crlRetention = 7 * 24 * time.Hour
+
 //Sqlite3Controller controller for sqlite3 database
 type Sqlite3Controller struct {
 	Connection *sql.DB
@@ -82,11 +90,139 @@ func (db *Sqlite3Controller) AddUserDomain(domain *storage.UserDomain) (bool, er
 
 //addUserDomain - add tracked domain to user processing, expected external transaction
 func addUserDomain(domain *storage.UserDomain, tx *sql.Tx) (bool, error) {
-	stmt, err := tx.Prepare("insert into UserDomains(UserId, Domain) values (?, ?);")
+	stmt, err := tx.Prepare("insert into UserDomains(UserId, Domain, PinnedIP, Proxy, AuditEnabled, AuditSummary) values (?, ?, ?, ?, ?, ?);")
+	if err != nil {
+		return false, err
+	}
+	result, err := tx.Stmt(stmt).Exec(domain.UserId, domain.Domain, domain.PinnedIP, domain.Proxy, domain.AuditEnabled, domain.AuditSummary)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		return true, nil
+	}
+	return false, nil
+}
+
+//UpdateUserDomain - updates tracked domain settings, domain is searched by user, domain name and pinned IP
+//Only settings owned by user are updated, state of scheduled checks and audits is updated by UpdateDomainCheckState and UpdateDomainAuditSummary
+func (db *Sqlite3Controller) UpdateUserDomain(domain *storage.UserDomain) (bool, error) {
+	tx, err := db.Connection.Begin()
+	if err != nil {
+		return false, err
+	}
+	result, err := updateUserDomain(domain, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return result, nil
+}
+
+//updateUserDomain - updates tracked domain settings processing, expected external transaction
+func updateUserDomain(domain *storage.UserDomain, tx *sql.Tx) (bool, error) {
+	stmt, err := tx.Prepare("update UserDomains" +
+		"	set Proxy = ?, AuditEnabled = ?, Pins = ?, ClientCert = ?" +
+		"	where UserId = ? and Domain = ? and PinnedIP = ?;")
+	if err != nil {
+		return false, err
+	}
+	result, err := tx.Stmt(stmt).Exec(domain.Proxy, domain.AuditEnabled, strings.Join(domain.Pins, pinsSeparator), domain.ClientCert,
+		domain.UserId, domain.Domain, domain.PinnedIP)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		return true, nil
+	}
+	return false, nil
+}
+
+//UpdateDomainCheckState - updates state of scheduled checks of domain: served leaf certificate, expiry alert, notified problems and check error
+//Domain settings are not updated, so settings changed by user while scheduled check are kept
+func (db *Sqlite3Controller) UpdateDomainCheckState(domain *storage.UserDomain) (bool, error) {
+	tx, err := db.Connection.Begin()
 	if err != nil {
 		return false, err
 	}
-	result, err := tx.Stmt(stmt).Exec(domain.UserId, domain.Domain)
+	result, err := updateDomainCheckState(domain, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return result, nil
+}
+
+//updateDomainCheckState - updates state of scheduled checks of domain processing, expected external transaction
+func updateDomainCheckState(domain *storage.UserDomain, tx *sql.Tx) (bool, error) {
+	stmt, err := tx.Prepare("update UserDomains" +
+		"	set CertFingerprint = ?, CertSerial = ?, CertIssuer = ?, CertNotAfter = ?, ExpiryAlerted = ?, ProblemsHash = ?, CheckErrorCategory = ?" +
+		"	where UserId = ? and Domain = ? and PinnedIP = ?;")
+	if err != nil {
+		return false, err
+	}
+	result, err := tx.Stmt(stmt).Exec(domain.CertFingerprint, domain.CertSerial, domain.CertIssuer, unixTime(domain.CertNotAfter), domain.ExpiryAlerted,
+		domain.ProblemsHash, domain.CheckErrorCategory, domain.UserId, domain.Domain, domain.PinnedIP)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		return true, nil
+	}
+	return false, nil
+}
+
+//UpdateDomainAuditSummary - updates result of last scheduled TLS audit of domain
+//Summary is not updated, if audit is disabled by user while scheduled audit
+func (db *Sqlite3Controller) UpdateDomainAuditSummary(domain *storage.UserDomain) (bool, error) {
+	tx, err := db.Connection.Begin()
+	if err != nil {
+		return false, err
+	}
+	result, err := updateDomainAuditSummary(domain, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return result, nil
+}
+
+//updateDomainAuditSummary - updates result of last scheduled TLS audit of domain processing, expected external transaction
+func updateDomainAuditSummary(domain *storage.UserDomain, tx *sql.Tx) (bool, error) {
+	stmt, err := tx.Prepare("update UserDomains" +
+		"	set AuditSummary = ?" +
+		"	where UserId = ? and Domain = ? and PinnedIP = ? and AuditEnabled = 1;")
+	if err != nil {
+		return false, err
+	}
+	result, err := tx.Stmt(stmt).Exec(domain.AuditSummary, domain.UserId, domain.Domain, domain.PinnedIP)
 	if err != nil {
 		return false, err
 	}
@@ -121,11 +257,11 @@ func (db *Sqlite3Controller) RemoveUserDomain(domain *storage.UserDomain) (bool,
 
 //removeUserDomain - remove tracked domain from user, expected external transaction
 func removeUserDomain(domain *storage.UserDomain, tx *sql.Tx) (bool, error) {
-	stmt, err := tx.Prepare("delete from UserDomains where UserId = ? and Domain = ?;")
+	stmt, err := tx.Prepare("delete from UserDomains where UserId = ? and Domain = ? and PinnedIP = ?;")
 	if err != nil {
 		return false, err
 	}
-	result, err := tx.Stmt(stmt).Exec(domain.UserId, domain.Domain)
+	result, err := tx.Stmt(stmt).Exec(domain.UserId, domain.Domain, domain.PinnedIP)
 	if err != nil {
 		return false, err
 	}
@@ -146,10 +282,16 @@ func (db *Sqlite3Controller) RemoveUser(user *storage.User) (bool, error) {
 		return false, err
 	}
 	_, err = removeAllUserDomains(user, tx)
-	result, err := removeUser(user, tx)
 	if err != nil {
+		_ = tx.Rollback()
 		return false, err
 	}
+	_, err = removeUserTrustStore(user, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	result, err := removeUser(user, tx)
 	if err != nil {
 		_ = tx.Rollback()
 		return false, err
@@ -317,7 +459,9 @@ func (db *Sqlite3Controller) GetUserByName(name string) (*storage.User, error) {
 
 //GetUserDomains - select user domains from database
 func (db *Sqlite3Controller) GetUserDomains(user *storage.User) (*[]storage.UserDomain, error) {
-	record, err := db.Connection.Query("select UserId, Domain from UserDomains where UserId = ?;", user.Id)
+	record, err := db.Connection.Query("select UserId, Domain, PinnedIP, Proxy, AuditEnabled, AuditSummary,"+
+		" CertFingerprint, CertSerial, CertIssuer, CertNotAfter, ExpiryAlerted, ProblemsHash, CheckErrorCategory, Pins, ClientCert"+
+		" from UserDomains where UserId = ? order by Domain, PinnedIP;", user.Id)
 	if err != nil {
 		return nil, err
 	}
@@ -329,10 +473,18 @@ func (db *Sqlite3Controller) GetUserDomains(user *storage.User) (*[]storage.User
 
 	for record.Next() {
 		var userDomain storage.UserDomain
-		err := record.Scan(&userDomain.UserId, &userDomain.Domain)
+		var certNotAfter int64
+		var pins string
+		err := record.Scan(&userDomain.UserId, &userDomain.Domain, &userDomain.PinnedIP, &userDomain.Proxy, &userDomain.AuditEnabled, &userDomain.AuditSummary,
+			&userDomain.CertFingerprint, &userDomain.CertSerial, &userDomain.CertIssuer, &certNotAfter, &userDomain.ExpiryAlerted,
+			&userDomain.ProblemsHash, &userDomain.CheckErrorCategory, &pins, &userDomain.ClientCert)
 		if err != nil {
 			return nil, err
 		}
+		userDomain.CertNotAfter = timeFromUnix(certNotAfter)
+		if pins != "" {
+			userDomain.Pins = strings.Split(pins, pinsSeparator)
+		}
 		userDomains = append(userDomains, userDomain)
 	}
 	if userDomains != nil {
@@ -368,6 +520,169 @@ func (db *Sqlite3Controller) GetUsersSchedules() (*[]storage.UserSchedule, error
 	return nil, storage.ErrorUsersSchedulesNotFound
 }
 
+//SaveCRL - saves downloaded CRL with its next update time, previous CRL with the same URL is replaced
+//CRLs of other URLs expired more than crlRetention ago are removed
+func (db *Sqlite3Controller) SaveCRL(URL string, raw []byte, nextUpdate time.Time) error {
+	tx, err := db.Connection.Begin()
+	if err != nil {
+		return err
+	}
+	err = saveCRL(URL, raw, nextUpdate, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+//saveCRL - saves downloaded CRL processing, expected external transaction
+func saveCRL(URL string, raw []byte, nextUpdate time.Time, tx *sql.Tx) error {
+	stmt, err := tx.Prepare("insert or replace into CRLs(URL, Data, NextUpdate, UpdatedAt) values (?, ?, ?, ?);")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	_, err = tx.Stmt(stmt).Exec(URL, raw, nextUpdate.Unix(), now.Unix())
+	if err != nil {
+		return err
+	}
+	return removeExpiredCRLs(URL, now.Add(-crlRetention), tx)
+}
+
+//removeExpiredCRLs - removes CRLs with next update time before expiredBefore except CRL of URL, expected external transaction
+func removeExpiredCRLs(URL string, expiredBefore time.Time, tx *sql.Tx) error {
+	stmt, err := tx.Prepare("delete from CRLs where NextUpdate < ? and URL <> ?;")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Stmt(stmt).Exec(expiredBefore.Unix(), URL)
+	return err
+}
+
+//GetCRL - select cached CRL and its next update time by URL
+func (db *Sqlite3Controller) GetCRL(URL string) ([]byte, time.Time, error) {
+	record, err := db.Connection.Query("select Data, NextUpdate from CRLs where URL = ?;", URL)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer func(record *sql.Rows) {
+		_ = record.Close()
+	}(record)
+
+	if record.Next() {
+		var raw []byte
+		var nextUpdate int64
+		err := record.Scan(&raw, &nextUpdate)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		return raw, time.Unix(nextUpdate, 0), nil
+	}
+
+	return nil, time.Time{}, storage.ErrorCRLNotFound
+}
+
+//SaveUserTrustStore - saves CA bundle of user, previous CA bundle of user is replaced
+func (db *Sqlite3Controller) SaveUserTrustStore(store *storage.UserTrustStore) error {
+	tx, err := db.Connection.Begin()
+	if err != nil {
+		return err
+	}
+	err = saveUserTrustStore(store, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+//saveUserTrustStore - saves CA bundle of user processing, expected external transaction
+func saveUserTrustStore(store *storage.UserTrustStore, tx *sql.Tx) error {
+	stmt, err := tx.Prepare("insert or replace into UserTrustStores(UserId, Roots, RootsOnly, UpdatedAt) values (?, ?, ?, ?);")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Stmt(stmt).Exec(store.UserId, store.Roots, store.RootsOnly, time.Now().Unix())
+	return err
+}
+
+//GetUserTrustStore - select CA bundle of user
+func (db *Sqlite3Controller) GetUserTrustStore(user *storage.User) (*storage.UserTrustStore, error) {
+	record, err := db.Connection.Query("select Roots, RootsOnly from UserTrustStores where UserId = ?;", user.Id)
+	if err != nil {
+		return nil, err
+	}
+	defer func(record *sql.Rows) {
+		_ = record.Close()
+	}(record)
+
+	if record.Next() {
+		store := storage.UserTrustStore{UserId: user.Id}
+		err := record.Scan(&store.Roots, &store.RootsOnly)
+		if err != nil {
+			return nil, err
+		}
+		return &store, nil
+	}
+
+	return nil, storage.ErrorUserTrustStoreNotFound
+}
+
+//RemoveUserTrustStore - remove CA bundle of user from database
+func (db *Sqlite3Controller) RemoveUserTrustStore(user *storage.User) (bool, error) {
+	tx, err := db.Connection.Begin()
+	if err != nil {
+		return false, err
+	}
+	result, err := removeUserTrustStore(user, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return result, nil
+}
+
+//removeUserTrustStore - remove CA bundle of user processing, expected external transaction
+func removeUserTrustStore(user *storage.User, tx *sql.Tx) (bool, error) {
+	stmt, err := tx.Prepare("delete from UserTrustStores where UserId = ?;")
+	if err != nil {
+		return false, err
+	}
+	result, err := tx.Stmt(stmt).Exec(user.Id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected > 0 {
+		return true, nil
+	}
+	return false, nil
+}
+
+//unixTime - returns unix time for storage, 0 for zero time
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+//timeFromUnix - returns time from stored unix time, zero time for 0
+func timeFromUnix(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
 //Dispose - close connections to database
 func (db *Sqlite3Controller) Dispose() {
 	CloseConnection(db.Connection)
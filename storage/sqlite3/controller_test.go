@@ -7,6 +7,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func getTempDBName() string {
@@ -359,10 +360,16 @@ func TestSqlite3Controller_GetUserDomains(t *testing.T) {
 				UserId: user.Id,
 				Domain: "test2.ru",
 			}
+			pinnedDomain := storage.UserDomain{
+				UserId:   user.Id,
+				Domain:   "test.com",
+				PinnedIP: "10.0.0.5",
+			}
 
 			var domains []storage.UserDomain
 
 			domains = append(domains, domain)
+			domains = append(domains, pinnedDomain)
 			domains = append(domains, domain2)
 
 			for _, dom := range domains {
@@ -453,10 +460,16 @@ func TestSqlite3Controller_RemoveUserDomain(t *testing.T) {
 				UserId: user.Id,
 				Domain: "test2.ru",
 			}
+			pinnedDomain := storage.UserDomain{
+				UserId:   user.Id,
+				Domain:   "test.com",
+				PinnedIP: "10.0.0.5",
+			}
 
 			var domains []storage.UserDomain
 
 			domains = append(domains, domain)
+			domains = append(domains, pinnedDomain)
 			domains = append(domains, domain2)
 
 			for _, dom := range domains {
@@ -487,6 +500,228 @@ func TestSqlite3Controller_RemoveUserDomain(t *testing.T) {
 	}
 }
 
+func TestSqlite3Controller_UpdateUserDomain(t *testing.T) {
+	dbName := getTempDBName()
+	defer removeDbFile(dbName)
+
+	db, _ := NewController(dbName)
+	defer db.Dispose()
+
+	user := storage.User{
+		Name: "test",
+		TGId: 11,
+	}
+	_, _ = db.AddUser(&user)
+	_, _ = db.AddUserDomain(&storage.UserDomain{UserId: user.Id, Domain: "test.com", AuditEnabled: true})
+	//state is saved by scheduled check and audit after domain is loaded by user command
+	state := storage.UserDomain{
+		UserId:             user.Id,
+		Domain:             "test.com",
+		AuditSummary:       `{"versions":["TLS 1.3"],"weak_cipher_suites":[]}`,
+		CertFingerprint:    "8CB0FC6C527506A053F4F14C8464BEBBD6DEDE2738D11468DD953D7D6A3021F1",
+		CertSerial:         "3A1F",
+		CertIssuer:         "CN=R3,O=Let's Encrypt,C=US",
+		CertNotAfter:       time.Unix(1672531200, 0),
+		ExpiryAlerted:      true,
+		ProblemsHash:       "3d5c0d2b8f1f0e6c1a6b2e5c5e0b9f8a4d3c2b1a0f9e8d7c6b5a49382716a5b4",
+		CheckErrorCategory: "timeout",
+	}
+	_, _ = db.UpdateDomainCheckState(&state)
+	_, _ = db.UpdateDomainAuditSummary(&state)
+
+	tests := []struct {
+		name     string
+		domain   string
+		pinnedIP string
+		want     bool
+		wantErr  bool
+	}{
+		{
+			name:    "test UpdateUserDomain success",
+			domain:  "test.com",
+			want:    true,
+			wantErr: false,
+		},
+		{
+			name:     "test UpdateUserDomain domain not found",
+			domain:   "test.com",
+			pinnedIP: "10.0.0.5",
+			want:     false,
+			wantErr:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			domain := storage.UserDomain{
+				UserId:       user.Id,
+				Domain:       tt.domain,
+				PinnedIP:     tt.pinnedIP,
+				Proxy:        "socks5://proxy.local:1080",
+				AuditEnabled: true,
+				Pins:         []string{"jQJTbIh0grw0/1TkHSumWb+Fs0Ggogr621gT3PvPKG0=", "C5+lpZ7tcVwmwQIMcRtPbsQtWLABXhQzejna0wHFr8M="},
+				ClientCert:   "internal",
+			}
+			got, err := db.UpdateUserDomain(&domain)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UpdateUserDomain() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("UpdateUserDomain() got = %v, want %v", got, tt.want)
+				return
+			}
+			if !tt.want {
+				return
+			}
+			result, err := db.GetUserDomains(&user)
+			if err != nil {
+				t.Errorf("UpdateUserDomain() return error %v", err)
+				return
+			}
+			//settings are updated, state is kept
+			want := domain
+			want.AuditSummary = state.AuditSummary
+			want.CertFingerprint, want.CertSerial, want.CertIssuer = state.CertFingerprint, state.CertSerial, state.CertIssuer
+			want.CertNotAfter, want.ExpiryAlerted, want.ProblemsHash = state.CertNotAfter, state.ExpiryAlerted, state.ProblemsHash
+			want.CheckErrorCategory = state.CheckErrorCategory
+			if !reflect.DeepEqual(result, &[]storage.UserDomain{want}) {
+				t.Errorf("UpdateUserDomain() got %v, want %v", result, want)
+			}
+		})
+	}
+}
+
+func TestSqlite3Controller_UpdateDomainCheckState(t *testing.T) {
+	dbName := getTempDBName()
+	defer removeDbFile(dbName)
+
+	db, _ := NewController(dbName)
+	defer db.Dispose()
+
+	user := storage.User{
+		Name: "test",
+		TGId: 11,
+	}
+	_, _ = db.AddUser(&user)
+	settings := storage.UserDomain{UserId: user.Id, Domain: "test.com"}
+	_, _ = db.AddUserDomain(&settings)
+	//settings are changed by user while scheduled check
+	settings.Proxy = "socks5://proxy.local:1080"
+	settings.Pins = []string{"jQJTbIh0grw0/1TkHSumWb+Fs0Ggogr621gT3PvPKG0="}
+	settings.ClientCert = "internal"
+	_, _ = db.UpdateUserDomain(&settings)
+
+	tests := []struct {
+		name     string
+		pinnedIP string
+		want     bool
+	}{
+		{
+			name: "test UpdateDomainCheckState success",
+			want: true,
+		},
+		{
+			name:     "test UpdateDomainCheckState domain not found",
+			pinnedIP: "10.0.0.5",
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//domain is loaded by scheduler before settings are changed
+			state := storage.UserDomain{
+				UserId:             user.Id,
+				Domain:             "test.com",
+				PinnedIP:           tt.pinnedIP,
+				CertFingerprint:    "8CB0FC6C527506A053F4F14C8464BEBBD6DEDE2738D11468DD953D7D6A3021F1",
+				CertSerial:         "3A1F",
+				CertIssuer:         "CN=R3,O=Let's Encrypt,C=US",
+				CertNotAfter:       time.Unix(1672531200, 0),
+				ExpiryAlerted:      true,
+				ProblemsHash:       "3d5c0d2b8f1f0e6c1a6b2e5c5e0b9f8a4d3c2b1a0f9e8d7c6b5a49382716a5b4",
+				CheckErrorCategory: "timeout",
+			}
+			got, err := db.UpdateDomainCheckState(&state)
+			if err != nil {
+				t.Fatalf("UpdateDomainCheckState() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("UpdateDomainCheckState() got = %v, want %v", got, tt.want)
+			}
+			if !tt.want {
+				return
+			}
+			result, err := db.GetUserDomains(&user)
+			if err != nil {
+				t.Fatalf("UpdateDomainCheckState() return error %v", err)
+			}
+			want := settings
+			want.CertFingerprint, want.CertSerial, want.CertIssuer = state.CertFingerprint, state.CertSerial, state.CertIssuer
+			want.CertNotAfter, want.ExpiryAlerted, want.ProblemsHash = state.CertNotAfter, state.ExpiryAlerted, state.ProblemsHash
+			want.CheckErrorCategory = state.CheckErrorCategory
+			if !reflect.DeepEqual(result, &[]storage.UserDomain{want}) {
+				t.Errorf("UpdateDomainCheckState() got %v, want %v", result, want)
+			}
+		})
+	}
+}
+
+func TestSqlite3Controller_UpdateDomainAuditSummary(t *testing.T) {
+	dbName := getTempDBName()
+	defer removeDbFile(dbName)
+
+	db, _ := NewController(dbName)
+	defer db.Dispose()
+
+	user := storage.User{
+		Name: "test",
+		TGId: 11,
+	}
+	_, _ = db.AddUser(&user)
+	_, _ = db.AddUserDomain(&storage.UserDomain{UserId: user.Id, Domain: "audited.com", AuditEnabled: true})
+	_, _ = db.AddUserDomain(&storage.UserDomain{UserId: user.Id, Domain: "disabled.com"})
+
+	tests := []struct {
+		name        string
+		domain      string
+		want        bool
+		wantSummary string
+	}{
+		{
+			name:        "test UpdateDomainAuditSummary success",
+			domain:      "audited.com",
+			want:        true,
+			wantSummary: `{"versions":["TLS 1.3"],"weak_cipher_suites":[]}`,
+		},
+		{
+			name:   "test UpdateDomainAuditSummary audit is disabled",
+			domain: "disabled.com",
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.UpdateDomainAuditSummary(&storage.UserDomain{UserId: user.Id, Domain: tt.domain,
+				AuditSummary: `{"versions":["TLS 1.3"],"weak_cipher_suites":[]}`})
+			if err != nil {
+				t.Fatalf("UpdateDomainAuditSummary() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("UpdateDomainAuditSummary() got = %v, want %v", got, tt.want)
+			}
+			domains, err := db.GetUserDomains(&user)
+			if err != nil {
+				t.Fatalf("UpdateDomainAuditSummary() return error %v", err)
+			}
+			for _, domain := range *domains {
+				if domain.Domain == tt.domain && domain.AuditSummary != tt.wantSummary {
+					t.Errorf("UpdateDomainAuditSummary() summary = %v, want %v", domain.AuditSummary, tt.wantSummary)
+				}
+			}
+		})
+	}
+}
+
 func Test_removeAllUserDomains(t *testing.T) {
 	dbName := getTempDBName()
 	defer removeDbFile(dbName)
@@ -563,11 +798,12 @@ func TestSqlite3Controller_RemoveUser(t *testing.T) {
 	defer removeDbFile(dbName)
 
 	tests := []struct {
-		name       string
-		want       bool
-		wantErr    bool
-		errDomains error
-		errUser    error
+		name            string
+		dropTrustStores bool
+		want            bool
+		wantErr         bool
+		errDomains      error
+		errUser         error
 	}{
 		{
 			name:       "test RemoveUser success",
@@ -576,6 +812,12 @@ func TestSqlite3Controller_RemoveUser(t *testing.T) {
 			errDomains: storage.ErrorUserDomainNotFound,
 			errUser:    storage.ErrorUserNotFound,
 		},
+		{
+			name:            "test RemoveUser rollback on error",
+			dropTrustStores: true,
+			want:            false,
+			wantErr:         true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -606,6 +848,10 @@ func TestSqlite3Controller_RemoveUser(t *testing.T) {
 			for _, dom := range domains {
 				_, _ = db.AddUserDomain(&dom)
 			}
+			_ = db.SaveUserTrustStore(&storage.UserTrustStore{UserId: user.Id, Roots: []byte("roots")})
+			if tt.dropTrustStores {
+				_, _ = db.Connection.Exec("drop table UserTrustStores;")
+			}
 
 			got, err := db.RemoveUser(&user)
 			if (err != nil) != tt.wantErr {
@@ -616,6 +862,15 @@ func TestSqlite3Controller_RemoveUser(t *testing.T) {
 				t.Errorf("RemoveUser() got = %v, want %v", got, tt.want)
 				return
 			}
+			if tt.wantErr {
+				if userDomains, err := db.GetUserDomains(&user); err != nil || len(*userDomains) != len(domains) {
+					t.Errorf("RemoveUser() domains are removed after error, got = %v, %v", userDomains, err)
+				}
+				if _, err := db.GetUserById(user.Id); err != nil {
+					t.Errorf("RemoveUser() user is removed after error, got = %v", err)
+				}
+				return
+			}
 			_, err = db.GetUserDomains(&user)
 			if err == nil {
 				t.Errorf("RemoveUser() expected error %s", tt.errDomains)
@@ -634,6 +889,11 @@ func TestSqlite3Controller_RemoveUser(t *testing.T) {
 				t.Errorf("RemoveUser() got = %v, want %s", err, tt.errUser)
 				return
 			}
+			_, err = db.GetUserTrustStore(&user)
+			if !errors.Is(err, storage.ErrorUserTrustStoreNotFound) {
+				t.Errorf("RemoveUser() trust store error = %v, want %s", err, storage.ErrorUserTrustStoreNotFound)
+				return
+			}
 		})
 	}
 }
@@ -785,3 +1045,161 @@ func TestSqlite3Controller_GetUsersSchedules1(t *testing.T) {
 		})
 	}
 }
+
+func TestSqlite3Controller_CRL(t *testing.T) {
+	dbName := getTempDBName()
+	defer removeDbFile(dbName)
+
+	db, _ := NewController(dbName)
+	defer db.Dispose()
+
+	nextUpdate := time.Date(2022, 6, 2, 10, 0, 0, 0, time.UTC)
+	freshNextUpdate := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	tests := []struct {
+		name           string
+		save           []byte
+		saveURL        string
+		saveNextUpdate time.Time
+		URL            string
+		want           []byte
+		wantNextUpdate time.Time
+		wantErr        error
+	}{
+		{
+			name:    "test GetCRL not found",
+			URL:     "http://crl.example.com/ca.crl",
+			wantErr: storage.ErrorCRLNotFound,
+		},
+		{
+			name:           "test SaveCRL new CRL",
+			save:           []byte{1, 2, 3},
+			saveURL:        "http://crl.example.com/ca.crl",
+			saveNextUpdate: nextUpdate,
+			URL:            "http://crl.example.com/ca.crl",
+			want:           []byte{1, 2, 3},
+			wantNextUpdate: nextUpdate,
+		},
+		{
+			name:           "test SaveCRL replace CRL",
+			save:           []byte{4, 5},
+			saveURL:        "http://crl.example.com/ca.crl",
+			saveNextUpdate: nextUpdate,
+			URL:            "http://crl.example.com/ca.crl",
+			want:           []byte{4, 5},
+			wantNextUpdate: nextUpdate,
+		},
+		{
+			name:           "test SaveCRL removes expired CRLs",
+			save:           []byte{6},
+			saveURL:        "http://crl.example.com/other.crl",
+			saveNextUpdate: freshNextUpdate,
+			URL:            "http://crl.example.com/ca.crl",
+			wantErr:        storage.ErrorCRLNotFound,
+		},
+		{
+			name:           "test SaveCRL keeps not expired CRLs",
+			save:           []byte{7},
+			saveURL:        "http://crl.example.com/ca.crl",
+			saveNextUpdate: freshNextUpdate,
+			URL:            "http://crl.example.com/other.crl",
+			want:           []byte{6},
+			wantNextUpdate: freshNextUpdate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.save != nil {
+				if err := db.SaveCRL(tt.saveURL, tt.save, tt.saveNextUpdate); err != nil {
+					t.Errorf("SaveCRL() error = %v", err)
+					return
+				}
+			}
+			got, gotNextUpdate, err := db.GetCRL(tt.URL)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetCRL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) || !gotNextUpdate.Equal(tt.wantNextUpdate) {
+				t.Errorf("GetCRL() got = %v, %v, want %v, %v", got, gotNextUpdate, tt.want, tt.wantNextUpdate)
+			}
+		})
+	}
+}
+
+func TestSqlite3Controller_UserTrustStore(t *testing.T) {
+	dbName := getTempDBName()
+	defer removeDbFile(dbName)
+
+	db, _ := NewController(dbName)
+	defer db.Dispose()
+
+	user := storage.User{
+		Name: "test",
+		TGId: 12,
+	}
+	_, _ = db.AddUser(&user)
+
+	tests := []struct {
+		name       string
+		save       *storage.UserTrustStore
+		remove     bool
+		want       *storage.UserTrustStore
+		wantRemove bool
+		wantErr    error
+	}{
+		{
+			name:    "test GetUserTrustStore not found",
+			wantErr: storage.ErrorUserTrustStoreNotFound,
+		},
+		{
+			name: "test SaveUserTrustStore new trust store",
+			save: &storage.UserTrustStore{UserId: user.Id, Roots: []byte("roots")},
+			want: &storage.UserTrustStore{UserId: user.Id, Roots: []byte("roots")},
+		},
+		{
+			name: "test SaveUserTrustStore replace trust store",
+			save: &storage.UserTrustStore{UserId: user.Id, Roots: []byte("new roots"), RootsOnly: true},
+			want: &storage.UserTrustStore{UserId: user.Id, Roots: []byte("new roots"), RootsOnly: true},
+		},
+		{
+			name:       "test RemoveUserTrustStore",
+			remove:     true,
+			wantRemove: true,
+			wantErr:    storage.ErrorUserTrustStoreNotFound,
+		},
+		{
+			name:       "test RemoveUserTrustStore not found",
+			remove:     true,
+			wantRemove: false,
+			wantErr:    storage.ErrorUserTrustStoreNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.save != nil {
+				if err := db.SaveUserTrustStore(tt.save); err != nil {
+					t.Errorf("SaveUserTrustStore() error = %v", err)
+					return
+				}
+			}
+			if tt.remove {
+				removed, err := db.RemoveUserTrustStore(&user)
+				if err != nil {
+					t.Errorf("RemoveUserTrustStore() error = %v", err)
+					return
+				}
+				if removed != tt.wantRemove {
+					t.Errorf("RemoveUserTrustStore() got = %v, want %v", removed, tt.wantRemove)
+				}
+			}
+			got, err := db.GetUserTrustStore(&user)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetUserTrustStore() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetUserTrustStore() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
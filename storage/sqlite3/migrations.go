@@ -4,12 +4,19 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"golang.org/x/net/idna"
+	"log"
+	"net"
+	"strconv"
+	"strings"
 )
 
 //Migration type for migration
 type Migration struct {
 	Version         int
 	MigrationScript string
+	//MigrationFunc - migration, which cannot be written as SQL script. Runs after MigrationScript in the same transaction, can be nil
+	MigrationFunc func(tx *sql.Tx) error
 }
 
 //getMigrations slice of database migrations
@@ -17,6 +24,7 @@ type Migration struct {
 //Migration.Version - must be a greater, then previous version Migration.Version++
 //Migration.MigrationScript - must contain a valid migration script,
 //that must migrate database from previous version, to the new specified one
+//Migration.MigrationFunc - can be set instead of script, if migration needs Go code
 //ADD ONLY SORTED ARRAY
 func getMigrations() []Migration {
 	return []Migration{
@@ -40,7 +48,241 @@ func getMigrations() []Migration {
 			"	PRIMARY KEY (UserId, Domain)," +
 			"	FOREIGN KEY(UserId) REFERENCES Users(Id)" +
 			");"},
+		{Version: 2, MigrationScript: "" +
+			"CREATE TABLE UserDomains_new (" +
+			"	UserId INTEGER," +
+			"	Domain varchar(4000)," +
+			"	PinnedIP varchar(64) NOT NULL DEFAULT ''," +
+			"	PRIMARY KEY (UserId, Domain, PinnedIP)," +
+			"	FOREIGN KEY(UserId) REFERENCES Users(Id)" +
+			");" +
+			"INSERT INTO UserDomains_new(UserId, Domain) SELECT UserId, Domain FROM UserDomains;" +
+			"DROP TABLE UserDomains;" +
+			"ALTER TABLE UserDomains_new RENAME TO UserDomains;"},
+		{Version: 3, MigrationScript: "" +
+			"ALTER TABLE UserDomains ADD COLUMN Proxy varchar(4000) NOT NULL DEFAULT '';"},
+		{Version: 4, MigrationScript: "" +
+			"ALTER TABLE UserDomains ADD COLUMN AuditEnabled INTEGER NOT NULL DEFAULT 0;" +
+			"ALTER TABLE UserDomains ADD COLUMN AuditSummary varchar(4000) NOT NULL DEFAULT '';"},
+		{Version: 5, MigrationScript: "" +
+			"CREATE TABLE CRLs (" +
+			"	URL varchar(4000)," +
+			"	Data BLOB NOT NULL," +
+			"	NextUpdate INTEGER NOT NULL," +
+			"	UpdatedAt INTEGER NOT NULL," +
+			"	PRIMARY KEY (URL)" +
+			");"},
+		{Version: 6, MigrationScript: "" +
+			"ALTER TABLE UserDomains ADD COLUMN CertFingerprint varchar(64) NOT NULL DEFAULT '';" +
+			"ALTER TABLE UserDomains ADD COLUMN CertSerial varchar(128) NOT NULL DEFAULT '';" +
+			"ALTER TABLE UserDomains ADD COLUMN CertIssuer varchar(4000) NOT NULL DEFAULT '';" +
+			"ALTER TABLE UserDomains ADD COLUMN CertNotAfter INTEGER NOT NULL DEFAULT 0;" +
+			"ALTER TABLE UserDomains ADD COLUMN ExpiryAlerted INTEGER NOT NULL DEFAULT 0;"},
+		{Version: 7, MigrationScript: "" +
+			"ALTER TABLE UserDomains ADD COLUMN Pins varchar(4000) NOT NULL DEFAULT '';"},
+		{Version: 8, MigrationScript: "" +
+			"DELETE FROM UserDomains WHERE rowid NOT IN (SELECT min(rowid) FROM UserDomains GROUP BY UserId, lower(Domain), PinnedIP);" +
+			"UPDATE UserDomains SET Domain = lower(Domain);"},
+		{Version: 9, MigrationScript: "" +
+			"ALTER TABLE UserDomains ADD COLUMN ClientCert varchar(4000) NOT NULL DEFAULT '';"},
+		{Version: 10, MigrationScript: "" +
+			"CREATE TABLE UserTrustStores (" +
+			"	UserId INTEGER," +
+			"	Roots BLOB NOT NULL," +
+			"	RootsOnly INTEGER NOT NULL DEFAULT 0," +
+			"	UpdatedAt INTEGER NOT NULL," +
+			"	PRIMARY KEY (UserId)," +
+			"	FOREIGN KEY(UserId) REFERENCES Users(Id)" +
+			");"},
+		{Version: 11, MigrationFunc: canonicalizeUserDomains},
+		{Version: 12, MigrationScript: "" +
+			"ALTER TABLE UserDomains ADD COLUMN ProblemsHash varchar(64) NOT NULL DEFAULT '';"},
+		{Version: 13, MigrationScript: "" +
+			"ALTER TABLE UserDomains ADD COLUMN CheckErrorCategory varchar(32) NOT NULL DEFAULT '';"},
+	}
+}
+
+//migrationProtocolPorts - default ports of STARTTLS protocols at version 11, default port is omitted in canonical domain
+var migrationProtocolPorts = map[string]string{
+	"":         "443",
+	"smtp":     "25",
+	"imap":     "143",
+	"pop3":     "110",
+	"ftp":      "21",
+	"xmpp":     "5222",
+	"ldap":     "389",
+	"postgres": "5432",
+}
+
+//domainRow - row of UserDomains table at version 11, which is canonicalized by migration
+type domainRow struct {
+	rowid        int64
+	userId       int
+	domain       string
+	pinnedIP     string
+	proxy        string
+	auditEnabled bool
+	auditSummary string
+	pins         string
+	clientCert   string
+	changed      bool
+}
+
+//canonicalDomain - returns stored domain in canonical form at version 11: [protocol://]host[:port], host in lower case,
+//IDN in punycode, without trailing dot, IPv6 in canonical form, default port of protocol is omitted
+//Logic is copied from target parser of version 11, so result of migration does not depend on later changes of parser
+func canonicalDomain(domain string) (string, error) {
+	protocol, hostPort := "", domain
+	if i := strings.Index(domain, "://"); i != -1 {
+		protocol, hostPort = strings.ToLower(domain[:i]), domain[i+3:]
+	}
+	defaultPort, ok := migrationProtocolPorts[protocol]
+	if !ok {
+		return "", fmt.Errorf("unsupported protocol %s", protocol)
+	}
+
+	host, port := hostPort, defaultPort
+	switch {
+	case strings.HasPrefix(hostPort, "[") && strings.HasSuffix(hostPort, "]"):
+		host = hostPort[1 : len(hostPort)-1]
+	case strings.HasPrefix(hostPort, "[") || strings.Count(hostPort, ":") == 1:
+		h, p, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			return "", err
+		}
+		host, port = h, p
+	}
+	if portNumber, err := strconv.Atoi(port); err != nil || portNumber < 1 || portNumber > 65535 {
+		return "", fmt.Errorf("incorrect port %s", port)
+	}
+
+	host = strings.TrimSuffix(host, ".")
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	} else if strings.Contains(host, ":") {
+		return "", fmt.Errorf("incorrect IPv6 address %s", host)
+	} else {
+		ascii, err := idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", err
+		}
+		host = ascii
+	}
+	if host == "" {
+		return "", errors.New("empty host")
+	}
+
+	result := host
+	if strings.Contains(host, ":") {
+		result = "[" + host + "]"
+	}
+	if port != defaultPort {
+		result += ":" + port
+	}
+	if protocol != "" {
+		result = protocol + "://" + result
+	}
+	return result, nil
+}
+
+//canonicalizeUserDomains - converts domains to canonical form with canonicalDomain
+//Rows with the same canonical domain are merged into first added row: pins are joined, empty settings are taken from merged rows
+//Conflicting settings are reported to log, settings of first added row are kept. Domains, which cannot be parsed, are kept as is
+func canonicalizeUserDomains(tx *sql.Tx) error {
+	record, err := tx.Query("select rowid, UserId, Domain, PinnedIP, Proxy, AuditEnabled, AuditSummary, Pins, ClientCert from UserDomains order by rowid;")
+	if err != nil {
+		return err
+	}
+	var rows []*domainRow
+	for record.Next() {
+		row := &domainRow{}
+		err := record.Scan(&row.rowid, &row.userId, &row.domain, &row.pinnedIP, &row.proxy, &row.auditEnabled, &row.auditSummary, &row.pins, &row.clientCert)
+		if err != nil {
+			_ = record.Close()
+			return err
+		}
+		rows = append(rows, row)
+	}
+	_ = record.Close()
+	if err := record.Err(); err != nil {
+		return err
+	}
+
+	canonicalRows := map[string]*domainRow{}
+	var merged []*domainRow
+	for _, row := range rows {
+		domain, err := canonicalDomain(row.domain)
+		if err != nil {
+			log.Printf("migration: domain %s of user %d is kept as is, it cannot be parsed - %v", row.domain, row.userId, err)
+			domain = row.domain
+		}
+
+		key := fmt.Sprintf("%d@%s@%s", row.userId, domain, row.pinnedIP)
+		first, ok := canonicalRows[key]
+		if !ok {
+			canonicalRows[key] = row
+			row.changed = row.domain != domain
+			row.domain = domain
+			continue
+		}
+		mergeDomainRow(first, row)
+		merged = append(merged, row)
 	}
+
+	for _, row := range merged {
+		if _, err := tx.Exec("delete from UserDomains where rowid = ?;", row.rowid); err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		if !row.changed {
+			continue
+		}
+		_, err := tx.Exec("update UserDomains set Domain = ?, Proxy = ?, AuditEnabled = ?, AuditSummary = ?, Pins = ?, ClientCert = ? where rowid = ?;",
+			row.domain, row.proxy, row.auditEnabled, row.auditSummary, row.pins, row.clientCert, row.rowid)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//mergeDomainRow - merges settings of duplicate row into first row with the same canonical domain
+func mergeDomainRow(first *domainRow, duplicate *domainRow) {
+	log.Printf("migration: domain %s of user %d is merged into %s", duplicate.domain, duplicate.userId, first.domain)
+	first.changed = true
+	if first.proxy == "" {
+		first.proxy = duplicate.proxy
+	} else if duplicate.proxy != "" && duplicate.proxy != first.proxy {
+		log.Printf("migration: proxy of domain %s of user %d is conflicting with proxy of %s, proxy of %s is kept",
+			duplicate.domain, duplicate.userId, first.domain, first.domain)
+	}
+	if first.clientCert == "" {
+		first.clientCert = duplicate.clientCert
+	} else if duplicate.clientCert != "" && duplicate.clientCert != first.clientCert {
+		log.Printf("migration: client certificate of domain %s of user %d is conflicting with client certificate of %s, client certificate of %s is kept",
+			duplicate.domain, duplicate.userId, first.domain, first.domain)
+	}
+	if !first.auditEnabled && duplicate.auditEnabled {
+		first.auditEnabled, first.auditSummary = true, duplicate.auditSummary
+	}
+	if duplicate.pins == "" {
+		return
+	}
+	pins := strings.Split(first.pins, pinsSeparator)
+	if first.pins == "" {
+		pins = nil
+	}
+	for _, pin := range strings.Split(duplicate.pins, pinsSeparator) {
+		exists := false
+		for _, existing := range pins {
+			exists = exists || existing == pin
+		}
+		if !exists {
+			pins = append(pins, pin)
+		}
+	}
+	first.pins = strings.Join(pins, pinsSeparator)
 }
 
 //isBaseStructExists - checks existing base struct for database
@@ -113,10 +355,19 @@ func migrateDatabase(migration Migration, db *sql.DB) (bool, error) {
 		return false, errors.New("database already migrate to this version")
 	}
 
-	_, err = tx.Exec(migration.MigrationScript)
-	if err != nil {
-		_ = tx.Rollback()
-		return false, err
+	if migration.MigrationScript != "" {
+		_, err = tx.Exec(migration.MigrationScript)
+		if err != nil {
+			_ = tx.Rollback()
+			return false, err
+		}
+	}
+	if migration.MigrationFunc != nil {
+		err = migration.MigrationFunc(tx)
+		if err != nil {
+			_ = tx.Rollback()
+			return false, err
+		}
 	}
 	_, err = setDBVersion(migration.Version, db, tx)
 	if err != nil {
@@ -2,9 +2,11 @@ package sqlite3
 
 import (
 	"database/sql"
+	"fmt"
 	"github.com/google/uuid"
 	"math/rand"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -697,3 +699,92 @@ func TestMigrateToActualVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestMigrateToActualVersion_keepUserDomains(t *testing.T) {
+	dbName, db := initDb()
+	defer removeDb(dbName, db)
+
+	_, err := migrateDatabase(getMigrations()[0], db)
+	if err != nil {
+		t.Fatalf("migrateDatabase() error = %v", err)
+	}
+	_, err = db.Exec("insert into Users(Id, Name, TGId, NotificationHour, UTC) values (1, 'test', 11, 0, 0);" +
+		"insert into UserDomains(UserId, Domain) values (1, 'test.com');")
+	if err != nil {
+		t.Fatalf("cannot fill database: %v", err)
+	}
+
+	_, err = MigrateToActualVersion(db)
+	if err != nil {
+		t.Fatalf("MigrateToActualVersion() error = %v", err)
+	}
+
+	var domain, pinnedIP string
+	err = db.QueryRow("select Domain, PinnedIP from UserDomains where UserId = 1;").Scan(&domain, &pinnedIP)
+	if err != nil {
+		t.Fatalf("cannot get user domain: %v", err)
+	}
+	if domain != "test.com" || pinnedIP != "" {
+		t.Errorf("MigrateToActualVersion() user domain = %s@%s, want test.com without pinned IP", domain, pinnedIP)
+	}
+}
+
+func TestMigrateToActualVersion_canonicalizeUserDomains(t *testing.T) {
+	dbName, db := initDb()
+	defer removeDb(dbName, db)
+
+	//database is already migrated by lower case migration of version 8
+	for _, migration := range getMigrations()[:10] {
+		if _, err := migrateDatabase(migration, db); err != nil {
+			t.Fatalf("migrateDatabase() error = %v", err)
+		}
+	}
+	_, err := db.Exec("insert into Users(Id, Name, TGId, NotificationHour, UTC) values (1, 'test', 11, 0, 0), (2, 'test 2', 12, 0, 0);" +
+		"insert into UserDomains(UserId, Domain, PinnedIP, Proxy, AuditEnabled, AuditSummary, Pins, ClientCert) values " +
+		"(1, 'example.com.', '', 'socks5://proxy.local:1080', 0, '', 'pin1', '')," +
+		"(1, 'example.com', '', 'http://proxy.local:3128', 1, '{}', 'pin2,pin1', 'internal')," +
+		"(1, 'example.com', '10.0.0.5', '', 0, '', '', '')," +
+		"(1, 'münchen.de:993', '', '', 0, '', '', '')," +
+		"(1, '*.example.com', '', '', 0, '', '', '')," +
+		"(1, '[2001:db8:0::1]:8443', '', '', 0, '', '', '')," +
+		"(1, 'smtp://mx.example.com:25', '', '', 0, '', '', '')," +
+		"(1, 'file:///etc/ssl/cert.pem', '', '', 0, '', '', '')," +
+		"(2, 'example.com', '', '', 0, '', 'pin3', '');")
+	if err != nil {
+		t.Fatalf("cannot fill database: %v", err)
+	}
+
+	_, err = MigrateToActualVersion(db)
+	if err != nil {
+		t.Fatalf("MigrateToActualVersion() error = %v", err)
+	}
+
+	record, err := db.Query("select UserId, Domain, PinnedIP, Proxy, AuditEnabled, AuditSummary, Pins, ClientCert from UserDomains order by UserId, Domain, PinnedIP;")
+	if err != nil {
+		t.Fatalf("cannot get user domains: %v", err)
+	}
+	defer record.Close()
+	var got []string
+	for record.Next() {
+		var userId int
+		var domain, pinnedIP, proxy, auditSummary, pins, clientCert string
+		var auditEnabled bool
+		if err := record.Scan(&userId, &domain, &pinnedIP, &proxy, &auditEnabled, &auditSummary, &pins, &clientCert); err != nil {
+			t.Fatalf("cannot scan user domain: %v", err)
+		}
+		got = append(got, fmt.Sprintf("%d %s@%s proxy=%s audit=%v summary=%s pins=%s cert=%s", userId, domain, pinnedIP, proxy, auditEnabled, auditSummary, pins, clientCert))
+	}
+	want := []string{
+		"1 *.example.com@ proxy= audit=false summary= pins= cert=",
+		"1 [2001:db8::1]:8443@ proxy= audit=false summary= pins= cert=",
+		"1 example.com@ proxy=socks5://proxy.local:1080 audit=true summary={} pins=pin1,pin2 cert=internal",
+		"1 example.com@10.0.0.5 proxy= audit=false summary= pins= cert=",
+		"1 file:///etc/ssl/cert.pem@ proxy= audit=false summary= pins= cert=",
+		"1 smtp://mx.example.com@ proxy= audit=false summary= pins= cert=",
+		"1 xn--mnchen-3ya.de:993@ proxy= audit=false summary= pins= cert=",
+		"2 example.com@ proxy= audit=false summary= pins=pin3 cert=",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MigrateToActualVersion() user domains = %v, want %v", got, want)
+	}
+}